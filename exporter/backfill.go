@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// invalid characters in metric names, like the "-" in "wan-tx_bytes".
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// BackfillOptions selects a historical report.
+type BackfillOptions struct {
+	Target   string
+	Site     string // name or description
+	Interval string // see unifi.ReportIntervals
+	Type     string // see unifi.ReportTypes
+	Attrs    []string
+	Start    time.Time
+	End      time.Time
+}
+
+// Backfill fetches a historical report and writes it in the OpenMetrics
+// format, suitable for "promtool tsdb create-blocks-from openmetrics".
+// Each attribute is written as unifi_sdn_report_<type>_<attribute>.
+func (cfg *Config) Backfill(ctx context.Context, w io.Writer, opts BackfillOptions) error {
+	client := cfg.clients[opts.Target]
+	if client == nil {
+		return fmt.Errorf("target %q not found", opts.Target)
+	}
+
+	site, err := findSite(ctx, client, opts.Site)
+	if err != nil {
+		return err
+	}
+
+	entries, err := client.Report(ctx, site.Name, opts.Interval, opts.Type, opts.Attrs, opts.Start, opts.End)
+	if err != nil {
+		return fmt.Errorf("fetching report failed: %w", err)
+	}
+
+	// series must be contiguous, and samples in ascending order
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	labels := []string{"site", "site_desc", "interval"}
+	if opts.Type != "site" {
+		labels = append(labels, "mac")
+	}
+
+	for _, attr := range opts.Attrs {
+		name := prometheus.BuildFQName("unifi_sdn", "report", opts.Type+"_"+invalidMetricChars.ReplaceAllString(attr, "_"))
+		help := fmt.Sprintf("attribute %s of the %s %s report", attr, opts.Interval, opts.Type)
+		desc := prometheus.NewDesc(name, help, labels, nil)
+
+		family := &dto.MetricFamily{
+			Name: &name,
+			Help: &help,
+			Type: dto.MetricType_GAUGE.Enum(),
+		}
+
+		for _, e := range entries {
+			v, ok := e.Values[attr]
+			if !ok {
+				continue
+			}
+
+			lv := []string{site.Name, site.Desc, opts.Interval}
+			if opts.Type != "site" {
+				lv = append(lv, e.Object)
+			}
+
+			m := &dto.Metric{}
+			sample := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+			if err := prometheus.NewMetricWithTimestamp(e.Time, sample).Write(m); err != nil {
+				return fmt.Errorf("encoding sample failed: %w", err)
+			}
+			family.Metric = append(family.Metric, m)
+		}
+
+		if len(family.Metric) == 0 {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+			return fmt.Errorf("writing metrics failed: %w", err)
+		}
+	}
+
+	if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+		return fmt.Errorf("writing metrics failed: %w", err)
+	}
+	return nil
+}
+
+// findSite looks up a site by name or description.
+func findSite(ctx context.Context, client unifi.Client, ident string) (*unifi.Site, error) {
+	sites, err := client.Sites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching sites failed: %w", err)
+	}
+
+	for _, s := range sites {
+		if s.Name == ident || s.Desc == ident {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("site %q not found", ident)
+}
@@ -0,0 +1,159 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// CheckConfig reports the configured targets. The config itself has
+// already been validated by LoadConfig. If login is set, each target
+// is contacted by listing its sites.
+func (cfg *Config) CheckConfig(ctx context.Context, w io.Writer, login bool) error {
+	failed := 0
+
+	for _, target := range cfg.targets("") {
+		if !login {
+			fmt.Fprintf(w, "%s: ok\n", target)
+			continue
+		}
+
+		sites, err := cfg.clients[target].Sites(ctx)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%s: login failed: %v\n", target, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s: ok (%d sites)\n", target, len(sites))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d controllers failed", failed, len(cfg.clients))
+	}
+	return nil
+}
+
+// ListSites writes the sites of a target (or of all targets, if target
+// is empty) as table.
+func (cfg *Config) ListSites(ctx context.Context, w io.Writer, target string) error {
+	targets := cfg.targets(target)
+	if len(targets) == 0 {
+		return fmt.Errorf("target %q not found", target)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSITE\tDESCRIPTION")
+
+	for _, t := range targets {
+		sites, err := cfg.clients[t].Sites(ctx)
+		if err != nil {
+			tw.Flush()
+			return fmt.Errorf("fetching sites of %s failed: %w", t, err)
+		}
+		for _, s := range sites {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", t, s.Name, s.Desc)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// Scrape collects the metrics of a site (or all sites, if site is
+// empty) and writes them in the text exposition format.
+func (cfg *Config) Scrape(ctx context.Context, w io.Writer, target, site string) error {
+	client := cfg.clients[target]
+	if client == nil {
+		return fmt.Errorf("target %q not found", target)
+	}
+	if site == "" {
+		site = allSites
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&unifiCollector{
+		client:      client,
+		ctx:         ctx,
+		site:        site,
+		parallelism: cfg.SiteParallelism,
+	})
+
+	families, err := reg.Gather()
+	if err != nil {
+		return fmt.Errorf("collecting metrics failed: %w", err)
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return fmt.Errorf("writing metrics failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Raw performs an API request and writes the indented JSON payload.
+// For POST requests, data is sent as request body.
+func (cfg *Config) Raw(ctx context.Context, w io.Writer, target, method, path, data string) error {
+	client := cfg.clients[target]
+	if client == nil {
+		return fmt.Errorf("target %q not found", target)
+	}
+
+	var res json.RawMessage
+	var err error
+
+	switch method {
+	case http.MethodGet:
+		err = client.Get(ctx, path, &res)
+	case http.MethodPost:
+		req := json.RawMessage("{}")
+		if data != "" {
+			if !json.Valid([]byte(data)) {
+				return errors.New("invalid JSON request body")
+			}
+			req = json.RawMessage(data)
+		}
+		err = client.Post(ctx, path, &req, &res)
+	default:
+		return fmt.Errorf("unsupported method %q", method)
+	}
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, res, "", "  "); err != nil {
+		return fmt.Errorf("formatting response failed: %w", err)
+	}
+	buf.WriteByte('\n')
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// targets returns the given target (if configured), or all targets
+// in alphabetical order, if target is empty.
+func (cfg *Config) targets(target string) []string {
+	if target != "" {
+		if _, ok := cfg.clients[target]; ok {
+			return []string{target}
+		}
+		return nil
+	}
+
+	targets := make([]string, 0, len(cfg.clients))
+	for t := range cfg.clients {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	return targets
+}
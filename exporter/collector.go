@@ -2,29 +2,80 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"log"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/digineo/unifi-sdn-exporter/unifi"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// allSites is the site parameter to collect all sites of a controller.
+const allSites = "*"
+
 type unifiCollector struct {
-	client unifi.Client
-	ctx    context.Context
-	site   string
+	client      unifi.Client
+	ctx         context.Context
+	site        string  // site name or description, or allSites
+	parallelism int     // maximum number of concurrently fetched sites
+	poller      *poller // serve snapshots instead of fetching, if set
+	streams     []*eventStream
 }
 
 var _ prometheus.Collector = (*unifiCollector)(nil)
 
 var (
-	ctrlUp = ctrlDesc("up", "indicator whether controller is reachable", "version")
+	ctrlUp        = ctrlDesc("up", "indicator whether controller is reachable", "version")
+	ctrlPollError = ctrlDesc("poll_error", "indicator whether the last poll of the site list failed (only in polling mode)")
+
+	scrapeTimeout = prometheus.NewDesc("unifi_sdn_scrape_timeout", "indicator whether the scrape deadline was exceeded and metrics are incomplete", nil, nil)
+
+	siteLabel = []string{"site", "site_desc"}
+	siteUp    = siteDesc("up", "indicator whether site metrics could be fetched")
+
+	siteStreamEvents = siteDesc("stream_events_total", "number of events received from the event stream since exporter start", "key", "subsystem")
+
+	siteLastSuccess = siteDesc("last_success_timestamp", "Unix timestamp of last successful poll")
+	siteSnapshotAge = siteDesc("snapshot_age_seconds", "age of the served metrics snapshot in seconds")
 
 	siteWifiUtil         = siteDesc("wifi_utilization", "average Wifi utilization", "band")
 	siteWifiClientsScore = siteDesc("wifi_client_score", "average client score") // 0-100?
 	siteWifiClientsCount = siteDesc("wifi_clients_count", "number of clients by rating", "rating")
 
-	devLabel       = []string{"mac"}
+	siteSubsystemStatus  = siteDesc("subsystem_status", "whether the subsystem status is ok", "subsystem", "status")
+	siteSubsystemDevices = siteDesc("subsystem_devices", "number of devices by subsystem and state", "subsystem", "state")
+	siteSubsystemClients = siteDesc("subsystem_clients", "number of clients by subsystem and type", "subsystem", "type")
+	siteWANInfo          = siteDesc("wan_info", "WAN address and gateway information", "ip", "isp_name", "isp_organization", "gateway_mac", "gateway_name", "gateway_version")
+	siteGatewayUptime    = siteDesc("gateway_uptime", "uptime of the gateway in seconds")
+	siteWWWLatency       = siteDesc("www_latency", "internet latency in milliseconds")
+	siteWWWUptime        = siteDesc("www_uptime", "internet uptime in seconds")
+	siteWWWDrops         = siteDesc("www_drops", "number of internet connection drops")
+	siteSpeedtestPing    = siteDesc("speedtest_ping", "ping of last speedtest in milliseconds")
+	siteSpeedtestDown    = siteDesc("speedtest_download", "download rate of last speedtest in MBit/s")
+	siteSpeedtestUp      = siteDesc("speedtest_upload", "upload rate of last speedtest in MBit/s")
+	siteSpeedtestLastRun = siteDesc("speedtest_last_run", "Unix timestamp of last speedtest")
+	siteVPNRemoteEnabled = siteDesc("vpn_remote_user_enabled", "whether remote user VPN is enabled")
+	siteVPNRemoteUsers   = siteDesc("vpn_remote_users", "number of remote user VPN sessions", "state")
+	siteVPNSiteToSite    = siteDesc("vpn_site_to_site_enabled", "whether site-to-site VPN is enabled")
+	siteVPNTunnels       = siteDesc("vpn_site_to_site_tunnels", "number of configured site-to-site VPN tunnels", "state")
+	siteWLANInfo         = siteDesc("wlan_info", "WLAN configuration", "wlan_id", "essid", "security", "vlan", "bands", "hidden", "guest")
+	siteWLANEnabled      = siteDesc("wlan_enabled", "whether the WLAN is enabled", "wlan_id", "essid")
+	siteSSIDClients      = siteDesc("ssid_clients", "number of connected clients by SSID", "essid")
+	siteSSIDRxBytes      = siteDesc("ssid_rx_bytes", "bytes received by SSID, summed over the current access points (not monotonic)", "essid")
+	siteSSIDTxBytes      = siteDesc("ssid_tx_bytes", "bytes transmitted by SSID, summed over the current access points (not monotonic)", "essid")
+	siteDevicesFirmware  = siteDesc("devices_by_firmware", "number of adopted devices by model and firmware version", "model_id", "model", "firmware")
+	siteAlarmsActive     = siteDesc("alarms_active", "number of active (unarchived) alarms", "key", "device_mac")
+	siteEvents           = siteDesc("events_total", "number of events since exporter start", "key")
+	siteEventGaps        = siteDesc("events_gaps_total", "number of event fetches, which may have missed events (see events_total)")
+
+	devLabel       = []string{"site", "site_desc", "mac"}
 	devStatus      = deviceDesc("status", "current device status", "desc", "model_id", "model", "firmware")
+	devInfo        = deviceDesc("info", "device name, type and addresses", "name", "type", "ip", "serial", "site_id")
+	devUpgradable  = deviceDesc("upgradable", "whether a firmware upgrade is available", "upgrade_to")
+	devModelLTS    = deviceDesc("model_in_lts", "whether the device model is in long-term support")
+	devModelEOL    = deviceDesc("model_in_eol", "whether the device model has reached its end of life")
 	devUptime      = deviceDesc("uptime", "uptime of device in seconds")
 	devLoad        = deviceDesc("load", "current system load of endpoint")
 	devClients     = deviceDesc("clients", "number of connected WLAN clients", "band")
@@ -33,16 +84,121 @@ var (
 	devPowerMax    = deviceDesc("power_max", "maximum power usage of the device in watts")
 	devPowerUsed   = deviceDesc("power_used", "current power usage of the device in watts")
 	devTemperature = deviceDesc("temperature", "current temperature of the device in Celsius")
+	devCPU         = deviceDesc("cpu", "current CPU usage of the device in percent")
+	devMemory      = deviceDesc("memory", "current memory usage of the device in percent")
+
+	devSpeedtestPing    = deviceDesc("speedtest_ping", "latency of last speedtest in milliseconds")
+	devSpeedtestDown    = deviceDesc("speedtest_download", "download rate of last speedtest in MBit/s")
+	devSpeedtestUp      = deviceDesc("speedtest_upload", "upload rate of last speedtest in MBit/s")
+	devSpeedtestLastRun = deviceDesc("speedtest_last_run", "Unix timestamp of last speedtest")
+
+	wanLabel        = []string{"site", "site_desc", "mac", "wan", "interface"}
+	wanInfo         = wanDesc("info", "WAN interface information", "ip")
+	wanUp           = wanDesc("up", "whether the WAN interface has a link")
+	wanEnabled      = wanDesc("enabled", "whether the WAN interface is enabled")
+	wanActive       = wanDesc("active", "whether the WAN interface is the current uplink")
+	wanSpeed        = wanDesc("speed", "link speed in MBit/s")
+	wanRxBytes      = wanDesc("rx_bytes_total", "number of bytes received")
+	wanTxBytes      = wanDesc("tx_bytes_total", "number of bytes transmitted")
+	wanLatency      = wanDesc("latency", "average latency in milliseconds")
+	wanAvailability = wanDesc("availability", "availability in percent")
+
+	radioLabel        = []string{"site", "site_desc", "mac", "radio", "band"}
+	radioChannel      = radioDesc("channel", "current WLAN channel")
+	radioChannelWidth = radioDesc("channel_width", "channel width in MHz")
+	radioTxPower      = radioDesc("tx_power", "transmit power in dBm")
+	radioClients      = radioDesc("clients", "number of connected clients")
+	radioSatisfaction = radioDesc("satisfaction", "average client satisfaction (0-100)")
+	radioUtilization  = radioDesc("channel_utilization", "channel utilization in percent", "type")
+	radioInterference = radioDesc("interference", "channel utilization not caused by the radio itself in percent")
+	radioTxPackets    = radioDesc("tx_packets_total", "number of packets transmitted")
+	radioTxRetries    = radioDesc("tx_retries_total", "number of transmit retries")
+	radioRxPackets    = radioDesc("rx_packets_total", "number of packets received")
+
+	vapLabel        = []string{"site", "site_desc", "mac", "radio", "band", "essid"}
+	vapClients      = vapDesc("clients", "number of connected clients")
+	vapRxBytes      = vapDesc("rx_bytes_total", "number of bytes received")
+	vapTxBytes      = vapDesc("tx_bytes_total", "number of bytes transmitted")
+	vapSatisfaction = vapDesc("satisfaction", "average client satisfaction (0-100)")
+
+	portLabel          = []string{"site", "site_desc", "mac", "port", "name"}
+	portUp             = portDesc("up", "whether the port has a link")
+	portEnabled        = portDesc("enabled", "whether the port is enabled")
+	portSpeed          = portDesc("speed", "link speed in MBit/s")
+	portFullDuplex     = portDesc("full_duplex", "whether the link is full duplex")
+	portSTPState       = portDesc("stp_state", "spanning tree state of the port", "state")
+	portRxBytes        = portDesc("rx_bytes_total", "number of bytes received")
+	portTxBytes        = portDesc("tx_bytes_total", "number of bytes transmitted")
+	portRxPackets      = portDesc("rx_packets_total", "number of packets received")
+	portTxPackets      = portDesc("tx_packets_total", "number of packets transmitted")
+	portRxErrors       = portDesc("rx_errors_total", "number of receive errors")
+	portTxErrors       = portDesc("tx_errors_total", "number of transmit errors")
+	portRxDropped      = portDesc("rx_dropped_total", "number of dropped received packets")
+	portTxDropped      = portDesc("tx_dropped_total", "number of dropped transmitted packets")
+	portPoEInfo        = portDesc("poe_info", "PoE mode and class of PoE capable ports", "mode", "class")
+	portPoEPower       = portDesc("poe_power", "PoE power output in watts")
+	portPoEVoltage     = portDesc("poe_voltage", "PoE voltage in volts")
+	portPoECurrent     = portDesc("poe_current", "PoE current in milliamperes")
+	portSFPTemperature = portDesc("sfp_temperature", "temperature of SFP module in Celsius")
+	portSFPRxPower     = portDesc("sfp_rx_power", "received optical power of SFP module")
+
+	staLabel        = []string{"site", "site_desc", "mac", "name", "hostname", "oui"}
+	staInfo         = stationDesc("info", "connection details of the client", "wired", "ap_mac", "sw_mac", "essid", "band", "vlan")
+	staUptime       = stationDesc("uptime", "connection time of client in seconds")
+	staTxBytes      = stationDesc("tx_bytes_total", "number of bytes transmitted")
+	staRxBytes      = stationDesc("rx_bytes_total", "number of bytes received")
+	staChannel      = stationDesc("channel", "WLAN channel of wireless client")
+	staRSSI         = stationDesc("rssi", "received signal strength of wireless client")
+	staSignal       = stationDesc("signal", "signal level of wireless client in dBm")
+	staNoise        = stationDesc("noise", "noise level of wireless client in dBm")
+	staTxRate       = stationDesc("tx_rate", "transmit rate of wireless client in kBit/s")
+	staRxRate       = stationDesc("rx_rate", "receive rate of wireless client in kBit/s")
+	staSatisfaction = stationDesc("satisfaction", "satisfaction score of wireless client (0-100)")
 )
 
 func (uc *unifiCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- ctrlUp
+	ch <- ctrlPollError
+	ch <- scrapeTimeout
 
+	ch <- siteUp
+	ch <- siteStreamEvents
+	ch <- siteLastSuccess
+	ch <- siteSnapshotAge
 	ch <- siteWifiUtil
 	ch <- siteWifiClientsScore
 	ch <- siteWifiClientsCount
+	ch <- siteSubsystemStatus
+	ch <- siteSubsystemDevices
+	ch <- siteSubsystemClients
+	ch <- siteWANInfo
+	ch <- siteGatewayUptime
+	ch <- siteWWWLatency
+	ch <- siteWWWUptime
+	ch <- siteWWWDrops
+	ch <- siteSpeedtestPing
+	ch <- siteSpeedtestDown
+	ch <- siteSpeedtestUp
+	ch <- siteSpeedtestLastRun
+	ch <- siteVPNRemoteEnabled
+	ch <- siteVPNRemoteUsers
+	ch <- siteVPNSiteToSite
+	ch <- siteVPNTunnels
+	ch <- siteWLANInfo
+	ch <- siteWLANEnabled
+	ch <- siteSSIDClients
+	ch <- siteSSIDRxBytes
+	ch <- siteSSIDTxBytes
+	ch <- siteDevicesFirmware
+	ch <- siteAlarmsActive
+	ch <- siteEvents
+	ch <- siteEventGaps
 
 	ch <- devStatus
+	ch <- devInfo
+	ch <- devUpgradable
+	ch <- devModelLTS
+	ch <- devModelEOL
 	ch <- devUptime
 	ch <- devLoad
 	ch <- devClients
@@ -51,32 +207,322 @@ func (uc *unifiCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- devPowerMax
 	ch <- devPowerUsed
 	ch <- devTemperature
+	ch <- devCPU
+	ch <- devMemory
+	ch <- devSpeedtestPing
+	ch <- devSpeedtestDown
+	ch <- devSpeedtestUp
+	ch <- devSpeedtestLastRun
+
+	ch <- wanInfo
+	ch <- wanUp
+	ch <- wanEnabled
+	ch <- wanActive
+	ch <- wanSpeed
+	ch <- wanRxBytes
+	ch <- wanTxBytes
+	ch <- wanLatency
+	ch <- wanAvailability
+
+	ch <- radioChannel
+	ch <- radioChannelWidth
+	ch <- radioTxPower
+	ch <- radioClients
+	ch <- radioSatisfaction
+	ch <- radioUtilization
+	ch <- radioInterference
+	ch <- radioTxPackets
+	ch <- radioTxRetries
+	ch <- radioRxPackets
+	ch <- vapClients
+	ch <- vapRxBytes
+	ch <- vapTxBytes
+	ch <- vapSatisfaction
+
+	ch <- portUp
+	ch <- portEnabled
+	ch <- portSpeed
+	ch <- portFullDuplex
+	ch <- portSTPState
+	ch <- portRxBytes
+	ch <- portTxBytes
+	ch <- portRxPackets
+	ch <- portTxPackets
+	ch <- portRxErrors
+	ch <- portTxErrors
+	ch <- portRxDropped
+	ch <- portTxDropped
+	ch <- portPoEInfo
+	ch <- portPoEPower
+	ch <- portPoEVoltage
+	ch <- portPoECurrent
+	ch <- portSFPTemperature
+	ch <- portSFPRxPower
+
+	ch <- staInfo
+	ch <- staUptime
+	ch <- staTxBytes
+	ch <- staRxBytes
+	ch <- staChannel
+	ch <- staRSSI
+	ch <- staSignal
+	ch <- staNoise
+	ch <- staTxRate
+	ch <- staRxRate
+	ch <- staSatisfaction
+}
+
+type siteResult struct {
+	site    unifi.Site
+	metrics *unifi.Metrics
+	err     error
+
+	// only in polling mode
+	lastSuccess time.Time
 }
 
 func (uc *unifiCollector) Collect(ch chan<- prometheus.Metric) {
+	const G = prometheus.GaugeValue
+
+	var results []siteResult
+	var err, pollErr error
+	if uc.poller != nil {
+		// on failure, the sites of the previous poll are served
+		pollErr = uc.poller.lastError()
+		ch <- prometheus.MustNewConstMetric(ctrlPollError, G, boolToFloat(pollErr != nil))
+		results, err = uc.poller.results(uc.site)
+	} else {
+		results, err = uc.fetch()
+	}
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(ctrlUp, G, 0, "")
+		ch <- prometheus.MustNewConstMetric(scrapeTimeout, G, boolToFloat(errors.Is(err, context.DeadlineExceeded)))
+		log.Println("fetching sites failed:", err)
+		return
+	}
+
+	// The controller is up, if the site list is current and at least
+	// one site could be fetched. Partial metrics don't count.
+	timedOut := false
+	fetched := len(results) == 0
+	version := ""
+	for _, res := range results {
+		if res.err != nil {
+			continue
+		}
+		fetched = true
+		if res.metrics != nil && version == "" {
+			version = res.metrics.ControllerVersion
+		}
+	}
+	if pollErr == nil && fetched {
+		ch <- prometheus.MustNewConstMetric(ctrlUp, G, 1, version)
+	} else {
+		ch <- prometheus.MustNewConstMetric(ctrlUp, G, 0, "")
+	}
+
+	for _, res := range results {
+		site := res.site
+		if res.metrics != nil {
+			site = res.metrics.Site
+		}
+
+		if res.err != nil {
+			log.Printf("fetching site %q failed: %v", site.Name, res.err)
+			timedOut = timedOut || errors.Is(res.err, context.DeadlineExceeded)
+		}
+		ch <- prometheus.MustNewConstMetric(siteUp, G, boolToFloat(res.err == nil), site.Name, site.Desc)
+
+		if !res.lastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(siteLastSuccess, G, float64(res.lastSuccess.Unix()), site.Name, site.Desc)
+			ch <- prometheus.MustNewConstMetric(siteSnapshotAge, G, time.Since(res.lastSuccess).Seconds(), site.Name, site.Desc)
+		}
+		if res.metrics != nil {
+			uc.collectSite(ch, res.metrics)
+		}
+
+		for _, es := range uc.streams {
+			if !es.matches(site) {
+				continue
+			}
+			counts, _ := es.snapshot()
+			for k, v := range counts {
+				ch <- prometheus.MustNewConstMetric(siteStreamEvents, prometheus.CounterValue, float64(v), site.Name, site.Desc, k.key, k.subsystem)
+			}
+			break // event-sites may list a site by name and description
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeTimeout, G, boolToFloat(timedOut))
+}
+
+// fetch retrieves the metrics for uc.site, or for all sites.
+func (uc *unifiCollector) fetch() ([]siteResult, error) {
+	sites, err := uc.client.Sites(uc.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A site requested by description is resolved, so that a failure
+	// is reported with the same labels as a success.
+	if uc.site != allSites {
+		site := unifi.Site{Name: uc.site}
+		for _, s := range sites {
+			if s.Name == uc.site || s.Desc == uc.site {
+				site = s
+				break
+			}
+		}
+		sites = []unifi.Site{site}
+	}
+
+	return fetchSites(uc.ctx, uc.client, sites, uc.parallelism), nil
+}
+
+// fetchSites retrieves the metrics for the given sites, with at most
+// parallelism requests in flight.
+func fetchSites(ctx context.Context, client unifi.Client, sites []unifi.Site, parallelism int) []siteResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	results := make([]siteResult, len(sites))
+	sem := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+	for i := range sites {
+		results[i].site = sites[i]
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *siteResult) {
+			defer func() { <-sem; wg.Done() }()
+
+			m, err := client.Metrics(ctx, res.site.Name)
+			res.err = err
+			if err == nil || errors.Is(err, context.DeadlineExceeded) {
+				res.metrics = m // partial results when the deadline is hit
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (uc *unifiCollector) collectSite(ch chan<- prometheus.Metric, m *unifi.Metrics) {
 	const C, G = prometheus.CounterValue, prometheus.GaugeValue
 
 	metric := func(desc *prometheus.Desc, typ prometheus.ValueType, v float64, label ...string) {
+		label = append([]string{m.Site.Name, m.Site.Desc}, label...)
 		ch <- prometheus.MustNewConstMetric(desc, typ, v, label...)
 	}
 
-	m, err := uc.client.Metrics(uc.ctx, uc.site)
-	if err != nil {
-		metric(ctrlUp, G, 0, "")
-		log.Println("fetching failed:", err)
-		return
+	// partial metrics (when the deadline is hit) lack some sections
+	if m.Has(unifi.SectionHealth) {
+		metric(siteWifiUtil, G, m.AvgWifiUtilization24, "2.4")
+		metric(siteWifiUtil, G, m.AvgWifiUtilization50, "5")
+		metric(siteWifiClientsScore, G, m.AvgWifiScore)
+		metric(siteWifiClientsCount, G, float64(m.ClientsPoorScore), "poor")
+		metric(siteWifiClientsCount, G, float64(m.ClientsFairScore), "fair")
+		metric(siteWifiClientsCount, G, float64(m.ClientsGoodScore), "good")
 	}
 
-	metric(ctrlUp, G, 1, m.ControllerVersion)
-	metric(siteWifiUtil, G, m.AvgWifiUtilization24, "2.4")
-	metric(siteWifiUtil, G, m.AvgWifiUtilization50, "5")
-	metric(siteWifiClientsScore, G, m.AvgWifiScore)
-	metric(siteWifiClientsCount, G, float64(m.ClientsPoorScore), "poor")
-	metric(siteWifiClientsCount, G, float64(m.ClientsFairScore), "fair")
-	metric(siteWifiClientsCount, G, float64(m.ClientsGoodScore), "good")
+	optional := func(desc *prometheus.Desc, v *int, label ...string) {
+		if v != nil {
+			metric(desc, G, float64(*v), label...)
+		}
+	}
+
+	for _, sub := range m.Subsystems {
+		metric(siteSubsystemStatus, G, boolToFloat(sub.Status == "ok"), sub.Name, sub.Status)
+		optional(siteSubsystemDevices, sub.Adopted, sub.Name, "adopted")
+		optional(siteSubsystemDevices, sub.Disconnected, sub.Name, "disconnected")
+		optional(siteSubsystemDevices, sub.Pending, sub.Name, "pending")
+		optional(siteSubsystemDevices, sub.Disabled, sub.Name, "disabled")
+		optional(siteSubsystemClients, sub.Users, sub.Name, "user")
+		optional(siteSubsystemClients, sub.Guests, sub.Name, "guest")
+		optional(siteSubsystemClients, sub.IoT, sub.Name, "iot")
+	}
+	if wan := m.WAN; wan != nil {
+		metric(siteWANInfo, G, 1, wan.IP, wan.ISPName, wan.ISPOrganization, wan.GatewayMAC, wan.GatewayName, wan.GatewayVersion)
+		if wan.GatewayUptime != nil {
+			metric(siteGatewayUptime, G, wan.GatewayUptime.Seconds())
+		}
+	}
+	if www := m.WWW; www != nil {
+		optional(siteWWWLatency, www.Latency)
+		optional(siteWWWDrops, www.Drops)
+		optional(siteSpeedtestPing, www.SpeedtestPing)
+		if www.Uptime != nil {
+			metric(siteWWWUptime, G, www.Uptime.Seconds())
+		}
+		if www.SpeedtestDown != nil {
+			metric(siteSpeedtestDown, G, *www.SpeedtestDown)
+		}
+		if www.SpeedtestUp != nil {
+			metric(siteSpeedtestUp, G, *www.SpeedtestUp)
+		}
+		if !www.SpeedtestLastRun.IsZero() {
+			metric(siteSpeedtestLastRun, G, float64(www.SpeedtestLastRun.Unix()))
+		}
+	}
+	if vpn := m.VPN; vpn != nil {
+		metric(siteVPNRemoteEnabled, G, boolToFloat(vpn.RemoteUserEnabled))
+		metric(siteVPNSiteToSite, G, boolToFloat(vpn.SiteToSiteEnabled))
+		optional(siteVPNRemoteUsers, vpn.RemoteUserActive, "active")
+		optional(siteVPNRemoteUsers, vpn.RemoteUserInactive, "inactive")
+	}
+	if m.Has(unifi.SectionNetworks) {
+		var enabled, disabled int
+		for _, t := range m.SiteToSiteTunnels {
+			if t.Enabled {
+				enabled++
+			} else {
+				disabled++
+			}
+		}
+		metric(siteVPNTunnels, G, float64(enabled), "enabled")
+		metric(siteVPNTunnels, G, float64(disabled), "disabled")
+	}
+	for _, w := range m.WLANs {
+		vlan := ""
+		if w.VLAN > 0 {
+			vlan = strconv.Itoa(w.VLAN)
+		}
+		metric(siteWLANInfo, G, 1, w.ID, w.ESSID, w.Security, vlan, w.Bands, strconv.FormatBool(w.Hidden), strconv.FormatBool(w.Guest))
+		metric(siteWLANEnabled, G, boolToFloat(w.Enabled), w.ID, w.ESSID)
+	}
+	for _, ssid := range m.SSIDs {
+		metric(siteSSIDClients, G, float64(ssid.Clients), ssid.ESSID)
+		metric(siteSSIDRxBytes, G, float64(ssid.RxBytes), ssid.ESSID)
+		metric(siteSSIDTxBytes, G, float64(ssid.TxBytes), ssid.ESSID)
+	}
+	for _, a := range m.Alarms {
+		metric(siteAlarmsActive, G, float64(a.Count), a.Key, a.DeviceMAC)
+	}
+	for key, n := range m.Events {
+		metric(siteEvents, C, float64(n), key)
+	}
+	if m.Has(unifi.SectionEvents) {
+		metric(siteEventGaps, C, float64(m.EventGaps))
+	}
+
+	type firmwareKey struct{ model, modelHuman, firmware string }
+	firmwares := make(map[firmwareKey]int)
+	for _, d := range m.Devices {
+		firmwares[firmwareKey{d.Model, d.ModelHuman, d.Firmware}]++
+	}
+	for k, n := range firmwares {
+		metric(siteDevicesFirmware, G, float64(n), k.model, k.modelHuman, k.firmware)
+	}
 
 	for _, d := range m.Devices {
 		metric(devStatus, G, float64(d.Status), d.MAC, d.StatusHuman, d.Model, d.ModelHuman, d.Firmware)
+		metric(devInfo, G, 1, d.MAC, d.Name, d.Type, d.IP, d.Serial, d.SiteID)
+		metric(devUpgradable, G, boolToFloat(d.Upgradable), d.MAC, d.UpgradeTo)
+		metric(devModelLTS, G, boolToFloat(d.LTS), d.MAC)
+		metric(devModelEOL, G, boolToFloat(d.EOL), d.MAC)
 
 		if !d.LastSeen.IsZero() {
 			metric(devLastSeen, G, float64(d.LastSeen.Unix()), d.MAC)
@@ -103,6 +549,137 @@ func (uc *unifiCollector) Collect(ch chan<- prometheus.Metric) {
 		if d.Temperature != nil {
 			metric(devTemperature, G, float64(*d.Temperature), d.MAC)
 		}
+		if d.CPU != nil {
+			metric(devCPU, G, *d.CPU, d.MAC)
+		}
+		if d.Memory != nil {
+			metric(devMemory, G, *d.Memory, d.MAC)
+		}
+
+		if st := d.Speedtest; st != nil {
+			metric(devSpeedtestPing, G, st.Latency, d.MAC)
+			metric(devSpeedtestDown, G, st.Download, d.MAC)
+			metric(devSpeedtestUp, G, st.Upload, d.MAC)
+			metric(devSpeedtestLastRun, G, float64(st.LastRun.Unix()), d.MAC)
+		}
+
+		for _, w := range d.WANs {
+			wl := []string{d.MAC, w.Name, w.Interface}
+			label := func(extra ...string) []string {
+				return append(wl[:len(wl):len(wl)], extra...)
+			}
+
+			metric(wanInfo, G, 1, label(w.IP)...)
+			metric(wanUp, G, boolToFloat(w.Up), label()...)
+			metric(wanEnabled, G, boolToFloat(w.Enabled), label()...)
+			metric(wanActive, G, boolToFloat(w.Active), label()...)
+			metric(wanSpeed, G, float64(w.Speed), label()...)
+			metric(wanRxBytes, C, float64(w.RxBytes), label()...)
+			metric(wanTxBytes, C, float64(w.TxBytes), label()...)
+			if w.Latency != nil {
+				metric(wanLatency, G, *w.Latency, label()...)
+			}
+			if w.Availability != nil {
+				metric(wanAvailability, G, *w.Availability, label()...)
+			}
+		}
+
+		for _, r := range d.RadioStats {
+			rl := []string{d.MAC, r.Name, r.Band}
+			label := func(extra ...string) []string {
+				return append(rl[:len(rl):len(rl)], extra...)
+			}
+
+			metric(radioChannel, G, float64(r.Channel), label()...)
+			if r.ChannelWidth > 0 {
+				metric(radioChannelWidth, G, float64(r.ChannelWidth), label()...)
+			}
+			metric(radioTxPower, G, float64(r.TxPower), label()...)
+			metric(radioClients, G, float64(r.Clients), label()...)
+			if r.Satisfaction != nil {
+				metric(radioSatisfaction, G, float64(*r.Satisfaction), label()...)
+			}
+			metric(radioUtilization, G, float64(r.CUTotal), label("total")...)
+			metric(radioUtilization, G, float64(r.CUSelfRx), label("self_rx")...)
+			metric(radioUtilization, G, float64(r.CUSelfTx), label("self_tx")...)
+			metric(radioInterference, G, float64(r.Interference), label()...)
+			metric(radioTxPackets, C, float64(r.TxPackets), label()...)
+			metric(radioTxRetries, C, float64(r.TxRetries), label()...)
+			metric(radioRxPackets, C, float64(r.RxPackets), label()...)
+		}
+
+		for _, v := range d.VAPs {
+			label := []string{d.MAC, v.Radio, v.Band, v.ESSID}
+			metric(vapClients, G, float64(v.Clients), label...)
+			metric(vapRxBytes, C, float64(v.RxBytes), label...)
+			metric(vapTxBytes, C, float64(v.TxBytes), label...)
+			if v.Satisfaction != nil {
+				metric(vapSatisfaction, G, float64(*v.Satisfaction), label...)
+			}
+		}
+
+		for _, p := range d.Ports {
+			pl := []string{d.MAC, strconv.Itoa(p.Index), p.Name}
+			label := func(extra ...string) []string {
+				return append(pl[:len(pl):len(pl)], extra...)
+			}
+
+			metric(portUp, G, boolToFloat(p.Up), label()...)
+			metric(portEnabled, G, boolToFloat(p.Enabled), label()...)
+			metric(portSpeed, G, float64(p.Speed), label()...)
+			metric(portFullDuplex, G, boolToFloat(p.FullDuplex), label()...)
+			if p.STPState != "" {
+				metric(portSTPState, G, 1, label(p.STPState)...)
+			}
+			metric(portRxBytes, C, float64(p.RxBytes), label()...)
+			metric(portTxBytes, C, float64(p.TxBytes), label()...)
+			metric(portRxPackets, C, float64(p.RxPackets), label()...)
+			metric(portTxPackets, C, float64(p.TxPackets), label()...)
+			metric(portRxErrors, C, float64(p.RxErrors), label()...)
+			metric(portTxErrors, C, float64(p.TxErrors), label()...)
+			metric(portRxDropped, C, float64(p.RxDropped), label()...)
+			metric(portTxDropped, C, float64(p.TxDropped), label()...)
+
+			if poe := p.PoE; poe != nil {
+				metric(portPoEInfo, G, 1, label(poe.Mode, poe.Class)...)
+				metric(portPoEPower, G, poe.Power, label()...)
+				metric(portPoEVoltage, G, poe.Voltage, label()...)
+				metric(portPoECurrent, G, poe.Current, label()...)
+			}
+			if sfp := p.SFP; sfp != nil {
+				metric(portSFPTemperature, G, sfp.Temperature, label()...)
+				metric(portSFPRxPower, G, sfp.RxPower, label()...)
+			}
+		}
+	}
+
+	for _, s := range m.Stations {
+		sl := []string{s.MAC, s.Name, s.Hostname, s.OUI}
+		label := func(extra ...string) []string {
+			return append(sl[:len(sl):len(sl)], extra...)
+		}
+
+		vlan := ""
+		if s.VLAN > 0 {
+			vlan = strconv.Itoa(s.VLAN)
+		}
+		metric(staInfo, G, 1, label(strconv.FormatBool(s.Wired), s.APMAC, s.SWMAC, s.ESSID, s.Band, vlan)...)
+		metric(staUptime, G, s.Uptime.Seconds(), label()...)
+		metric(staTxBytes, C, float64(s.TxBytes), label()...)
+		metric(staRxBytes, C, float64(s.RxBytes), label()...)
+
+		if s.Wired {
+			continue
+		}
+		metric(staChannel, G, float64(s.Channel), label()...)
+		metric(staRSSI, G, float64(s.RSSI), label()...)
+		metric(staSignal, G, float64(s.Signal), label()...)
+		metric(staNoise, G, float64(s.Noise), label()...)
+		metric(staTxRate, G, float64(s.TxRate), label()...)
+		metric(staRxRate, G, float64(s.RxRate), label()...)
+		if s.Satisfaction != nil {
+			metric(staSatisfaction, G, float64(*s.Satisfaction), label()...)
+		}
 	}
 }
 
@@ -113,10 +690,42 @@ func ctrlDesc(name, help string, extraLabel ...string) *prometheus.Desc {
 
 func siteDesc(name, help string, extraLabel ...string) *prometheus.Desc {
 	fqdn := prometheus.BuildFQName("unifi_sdn", "site", name)
-	return prometheus.NewDesc(fqdn, help, extraLabel, nil)
+	return prometheus.NewDesc(fqdn, help, append(siteLabel, extraLabel...), nil)
 }
 
 func deviceDesc(name, help string, extraLabel ...string) *prometheus.Desc {
 	fqdn := prometheus.BuildFQName("unifi_sdn", "device", name)
 	return prometheus.NewDesc(fqdn, help, append(devLabel, extraLabel...), nil)
 }
+
+func wanDesc(name, help string, extraLabel ...string) *prometheus.Desc {
+	fqdn := prometheus.BuildFQName("unifi_sdn", "wan", name)
+	return prometheus.NewDesc(fqdn, help, append(wanLabel, extraLabel...), nil)
+}
+
+func radioDesc(name, help string, extraLabel ...string) *prometheus.Desc {
+	fqdn := prometheus.BuildFQName("unifi_sdn", "radio", name)
+	return prometheus.NewDesc(fqdn, help, append(radioLabel, extraLabel...), nil)
+}
+
+func vapDesc(name, help string, extraLabel ...string) *prometheus.Desc {
+	fqdn := prometheus.BuildFQName("unifi_sdn", "vap", name)
+	return prometheus.NewDesc(fqdn, help, append(vapLabel, extraLabel...), nil)
+}
+
+func portDesc(name, help string, extraLabel ...string) *prometheus.Desc {
+	fqdn := prometheus.BuildFQName("unifi_sdn", "port", name)
+	return prometheus.NewDesc(fqdn, help, append(portLabel, extraLabel...), nil)
+}
+
+func stationDesc(name, help string, extraLabel ...string) *prometheus.Desc {
+	fqdn := prometheus.BuildFQName("unifi_sdn", "station", name)
+	return prometheus.NewDesc(fqdn, help, append(staLabel, extraLabel...), nil)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,287 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+	"github.com/digineo/unifi-sdn-exporter/unifi/fakecontroller"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather collects the metrics of uc, keyed by `name{label="value",...}`.
+func gather(t *testing.T, uc *unifiCollector) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(uc)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %v", err)
+	}
+
+	series := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			labels := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+			}
+			key := fmt.Sprintf("%s{%s}", mf.GetName(), strings.Join(labels, ","))
+
+			switch {
+			case m.Gauge != nil:
+				series[key] = m.GetGauge().GetValue()
+			case m.Counter != nil:
+				series[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	return series
+}
+
+func TestUnifiCollector(t *testing.T) {
+	const (
+		defaultSite = `site="default",site_desc="Default"`
+		branchSite  = `site="x7k2m9qp",site_desc="Branch Office"`
+		devicesPath = "/api/s/x7k2m9qp/stat/device"
+	)
+
+	tests := []struct {
+		name     string
+		unifiOS  bool
+		site     string
+		setup    func(*fakecontroller.Server)
+		deadline time.Duration
+		poll     bool
+		pollErr  error    // of the site list, after the first poll
+		streams  []string // sites with an event stream
+
+		want   map[string]float64
+		absent []string // metric names (or prefixes)
+	}{
+		{
+			name: "all sites",
+			site: allSites,
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version="8.0.28"}`:                                                           1,
+				`unifi_sdn_scrape_timeout{}`:                                                                          0,
+				`unifi_sdn_site_up{` + defaultSite + `}`:                                                              1,
+				`unifi_sdn_site_up{` + branchSite + `}`:                                                               1,
+				`unifi_sdn_site_wifi_utilization{band="5",` + defaultSite + `}`:                                       12.5,
+				`unifi_sdn_site_wifi_client_score{` + defaultSite + `}`:                                               87.3,
+				`unifi_sdn_site_vpn_site_to_site_tunnels{` + defaultSite + `,state="enabled"}`:                        1,
+				`unifi_sdn_site_vpn_site_to_site_tunnels{` + defaultSite + `,state="disabled"}`:                       1,
+				`unifi_sdn_site_wifi_clients_count{rating="good",` + defaultSite + `}`:                                11,
+				`unifi_sdn_site_wlan_enabled{essid="Example",` + defaultSite + `,wlan_id="6010a1b2c3d4e5f6a7b8c9d0"}`: 1,
+				`unifi_sdn_site_ssid_rx_bytes{essid="Example",` + defaultSite + `}`:                                   38024679,
+			},
+			absent: []string{"unifi_sdn_controller_poll_error", "unifi_sdn_site_last_success_timestamp", "unifi_sdn_site_ssid_rx_bytes_total"},
+		},
+		{
+			name:    "site by description on UniFi OS",
+			unifiOS: true,
+			site:    "Branch Office",
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version="8.0.28"}`: 1,
+				`unifi_sdn_site_up{` + branchSite + `}`:     1,
+			},
+			absent: []string{`unifi_sdn_site_up{` + defaultSite},
+		},
+		{
+			name: "failing site by description",
+			site: "Branch Office",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject(devicesPath, fakecontroller.Fault{Error: "api.err.NoPermission"})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`:   0,
+				`unifi_sdn_site_up{` + branchSite + `}`: 0,
+			},
+			absent: []string{`unifi_sdn_site_up{site="Branch Office"`},
+		},
+		{
+			name: "unknown site",
+			site: "unknown",
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`:            0,
+				`unifi_sdn_site_up{site="unknown",site_desc=""}`: 0,
+			},
+		},
+		{
+			name: "controller down",
+			site: allSites,
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/self/sites", fakecontroller.Fault{Status: http.StatusInternalServerError})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`: 0,
+				`unifi_sdn_scrape_timeout{}`:          0,
+			},
+			absent: []string{"unifi_sdn_site_", "unifi_sdn_device_"},
+		},
+		{
+			name: "site failure",
+			site: allSites,
+			setup: func(s *fakecontroller.Server) {
+				s.Inject(devicesPath, fakecontroller.Fault{Error: "api.err.NoPermission"})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version="8.0.28"}`: 1,
+				`unifi_sdn_site_up{` + defaultSite + `}`:    1,
+				`unifi_sdn_site_up{` + branchSite + `}`:     0,
+			},
+			absent: []string{`unifi_sdn_site_wifi_utilization{band="5",` + branchSite},
+		},
+		{
+			name: "network configuration failing",
+			site: "default",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/rest/networkconf", fakecontroller.Fault{Error: "api.err.NoPermission"})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version="8.0.28"}`: 1,
+				`unifi_sdn_site_up{` + defaultSite + `}`:    1,
+			},
+			absent: []string{"unifi_sdn_site_vpn_site_to_site_tunnels"},
+		},
+		{
+			name: "all sites failing",
+			site: allSites,
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/stat/device", fakecontroller.Fault{Malformed: true})
+				s.Inject(devicesPath, fakecontroller.Fault{Malformed: true})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`:    0,
+				`unifi_sdn_site_up{` + defaultSite + `}`: 0,
+				`unifi_sdn_site_up{` + branchSite + `}`:  0,
+			},
+		},
+		{
+			name:     "deadline",
+			site:     "default",
+			deadline: 300 * time.Millisecond,
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/stat/device", fakecontroller.Fault{Delay: time.Second})
+			},
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`:                           0,
+				`unifi_sdn_scrape_timeout{}`:                                    1,
+				`unifi_sdn_site_up{` + defaultSite + `}`:                        0,
+				`unifi_sdn_site_wifi_utilization{band="5",` + defaultSite + `}`: 12.5, // fetched before the deadline
+			},
+			absent: []string{"unifi_sdn_device_", "unifi_sdn_site_wlan_"},
+		},
+		{
+			name:     "deadline before health",
+			site:     "default",
+			deadline: 300 * time.Millisecond,
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/stat/widget/health", fakecontroller.Fault{Delay: time.Second})
+			},
+			want: map[string]float64{
+				`unifi_sdn_scrape_timeout{}`:             1,
+				`unifi_sdn_site_up{` + defaultSite + `}`: 0,
+			},
+			absent: []string{"unifi_sdn_site_wifi_", "unifi_sdn_site_vpn_"},
+		},
+		{
+			name: "polling",
+			site: allSites,
+			poll: true,
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version="8.0.28"}`: 1,
+				`unifi_sdn_controller_poll_error{}`:         0,
+				`unifi_sdn_site_up{` + defaultSite + `}`:    1,
+				`unifi_sdn_site_up{` + branchSite + `}`:     1,
+			},
+		},
+		{
+			name:    "stale site list",
+			site:    allSites,
+			poll:    true,
+			pollErr: errors.New("connection refused"),
+			want: map[string]float64{
+				`unifi_sdn_controller_up{version=""}`:    0,
+				`unifi_sdn_controller_poll_error{}`:      1,
+				`unifi_sdn_site_up{` + defaultSite + `}`: 1,
+			},
+		},
+		{
+			name:    "event stream configured by name and description",
+			site:    allSites,
+			streams: []string{"default", "Default"},
+			want: map[string]float64{
+				`unifi_sdn_site_stream_events_total{key="EVT_AP_Lost_Contact",` + defaultSite + `,subsystem="wlan"}`: 1,
+			},
+			absent: []string{"unifi_sdn_site_stream_events_total{key=\"EVT_AP_Lost_Contact\"," + branchSite},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakecontroller.New(tt.unifiOS)
+			defer srv.Close()
+
+			client, err := unifi.NewClient(&unifi.Controller{
+				URL:      srv.URL,
+				Username: fakecontroller.Username,
+				Password: fakecontroller.Password,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			uc := &unifiCollector{
+				client:      client,
+				ctx:         ctx,
+				site:        tt.site,
+				parallelism: 2,
+			}
+			if tt.poll {
+				uc.poller = newPoller(client, time.Minute, 2)
+				uc.poller.poll(ctx)
+				uc.poller.err = tt.pollErr
+			}
+			for _, site := range tt.streams {
+				es := newEventStream(client, site)
+				es.add(unifi.Event{Key: "EVT_AP_Lost_Contact", Subsystem: "wlan"})
+				uc.streams = append(uc.streams, es)
+			}
+
+			series := gather(t, uc)
+
+			for key, want := range tt.want {
+				if got, ok := series[key]; !ok {
+					t.Errorf("missing %s", key)
+				} else if got != want {
+					t.Errorf("got %s = %v, want %v", key, got, want)
+				}
+			}
+			for _, prefix := range tt.absent {
+				for key := range series {
+					if strings.HasPrefix(key, prefix) {
+						t.Errorf("unexpected %s", key)
+					}
+				}
+			}
+		})
+	}
+}
@@ -2,35 +2,120 @@ package exporter
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/digineo/unifi-sdn-exporter/unifi"
 )
 
 type Config struct {
+	// maximum number of sites fetched concurrently, when scraping all
+	// sites of a controller
+	SiteParallelism int `toml:"site-parallelism"`
+
+	// if set, sites are fetched periodically in the background, instead
+	// of at scrape time
+	PollInterval time.Duration `toml:"poll-interval"`
+
+	// subtracted from the X-Prometheus-Scrape-Timeout-Seconds header
+	// to leave some time for sending the response
+	ScrapeTimeoutOffset time.Duration `toml:"scrape-timeout-offset"`
+
 	// list of Unifi SDN controllers
 	Controllers []*unifi.Controller `toml:"unifi-controller"`
 
 	// Transformed controller instances. Key it the clients target identifier,
 	// i.e. the controller alias or the URL's host name.
 	clients map[string]unifi.Client
+
+	// background pollers, keyed like clients (only if PollInterval is set)
+	pollers map[string]*poller
+
+	// event streams, keyed by target and site (as configured)
+	streams map[streamKey]*eventStream
+
+	// path of the config file, used for reloading
+	file string
 }
 
 // LoadConfig loads the configuration from a file.
 func LoadConfig(file string) (*Config, error) {
-	cfg := Config{}
-	if _, err := toml.DecodeFile(file, &cfg); err != nil {
+	cfg := Config{
+		file:                file,
+		SiteParallelism:     4,
+		ScrapeTimeoutOffset: 500 * time.Millisecond,
+	}
+	md, err := toml.DecodeFile(file, &cfg)
+	if err != nil {
 		return nil, fmt.Errorf("loading config file %q failed: %w", file, err)
 	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("loading config file %q failed: unknown keys %s", file, strings.Join(keys, ", "))
+	}
 
 	cfg.clients = make(map[string]unifi.Client)
 	for i, ctrl := range cfg.Controllers {
+		if err := resolveSecrets(ctrl); err != nil {
+			return nil, fmt.Errorf("invalid controller #%d (%s): %w", i, controllerName(ctrl), err)
+		}
+
 		client, err := unifi.NewClient(ctrl)
 		if err != nil {
-			return nil, fmt.Errorf("invalid controller #%d (%v): %w", i, ctrl, err)
+			return nil, fmt.Errorf("invalid controller #%d (%s): %w", i, controllerName(ctrl), err)
 		}
 		cfg.clients[client.TargetName()] = client
 	}
 
 	return &cfg, nil
 }
+
+// startPollers starts a background poller for each client, if
+// PollInterval is configured. Pollers of prev (if not nil) are taken
+// over, if they poll the same client with the same settings.
+func (cfg *Config) startPollers(prev *Config) {
+	if cfg.PollInterval <= 0 {
+		return
+	}
+
+	cfg.pollers = make(map[string]*poller, len(cfg.clients))
+	for target, client := range cfg.clients {
+		if prev != nil {
+			p := prev.pollers[target]
+			if p != nil && p.client == client && p.interval == cfg.PollInterval && p.parallelism == cfg.SiteParallelism {
+				cfg.pollers[target] = p
+				continue
+			}
+		}
+
+		p := newPoller(client, cfg.PollInterval, cfg.SiteParallelism)
+		cfg.pollers[target] = p
+		p.start()
+	}
+}
+
+// stopPollers stops all pollers, which were not taken over by next.
+func (cfg *Config) stopPollers(next *Config) {
+	for target, p := range cfg.pollers {
+		if next == nil || next.pollers[target] != p {
+			p.stop()
+		}
+	}
+}
+
+// controllerName identifies a controller in error messages. Unlike
+// the controller itself, it doesn't contain credentials.
+func controllerName(ctrl *unifi.Controller) string {
+	if ctrl.Alias != "" {
+		return ctrl.Alias
+	}
+	if u, err := url.Parse(ctrl.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "unknown host"
+}
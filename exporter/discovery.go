@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// sdTargetGroup is a target group in the Prometheus HTTP SD format,
+// see https://prometheus.io/docs/prometheus/latest/http_sd/.
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// discoveryHandler lists one target group for each site of each
+// configured controller. The exporter itself (as seen by the client)
+// is the scrape target.
+func (cfg *Config) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	groups := []sdTargetGroup{}
+
+	for target, client := range cfg.clients {
+		sites, err := client.Sites(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error fetching sites for controller %s: %v", target, err), http.StatusInternalServerError)
+			return
+		}
+
+		for _, s := range sites {
+			groups = append(groups, sdTargetGroup{
+				Targets: []string{r.Host},
+				Labels: map[string]string{
+					"__param_target":         target,
+					"__param_site":           s.Name,
+					"__meta_unifi_site":      s.Name,
+					"__meta_unifi_site_desc": s.Desc,
+					"controller":             target,
+				},
+			})
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Labels, groups[j].Labels
+		if a["controller"] != b["controller"] {
+			return a["controller"] < b["controller"]
+		}
+		return a["__param_site"] < b["__param_site"]
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		log.Println("encoding service discovery response failed:", err)
+	}
+}
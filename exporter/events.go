@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"context"
+	_ "embed" //nolint:golint
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+)
+
+// number of events kept in memory per stream.
+const eventBufferSize = 200
+
+// eventStream counts the events received from a site's event stream
+// and keeps the most recent ones.
+type eventStream struct {
+	client unifi.Client
+	site   string // site name or description
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	counts map[eventKey]uint64
+	recent []unifi.Event // ring buffer
+	next   int           // next index in recent
+}
+
+type eventKey struct {
+	key, subsystem string
+}
+
+// streamKey identifies an event stream by target and site.
+type streamKey struct {
+	target, site string
+}
+
+func newEventStream(client unifi.Client, site string) *eventStream {
+	return &eventStream{
+		client: client,
+		site:   site,
+		counts: make(map[eventKey]uint64),
+		recent: make([]unifi.Event, 0, eventBufferSize),
+	}
+}
+
+func (es *eventStream) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	es.cancel = cancel
+	go es.client.SubscribeEvents(ctx, es.site, es.add)
+}
+
+func (es *eventStream) stop() {
+	if es.cancel != nil {
+		es.cancel()
+	}
+}
+
+func (es *eventStream) add(ev unifi.Event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.counts[eventKey{ev.Key, ev.Subsystem}]++
+
+	if len(es.recent) < eventBufferSize {
+		es.recent = append(es.recent, ev)
+	} else {
+		es.recent[es.next] = ev
+	}
+	es.next = (es.next + 1) % eventBufferSize
+}
+
+// matches reports whether the stream belongs to the given site.
+func (es *eventStream) matches(site unifi.Site) bool {
+	return es.site == site.Name || es.site == site.Desc
+}
+
+// snapshot returns a copy of the counters and the buffered events,
+// newest first.
+func (es *eventStream) snapshot() (map[eventKey]uint64, []unifi.Event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	counts := make(map[eventKey]uint64, len(es.counts))
+	for k, v := range es.counts {
+		counts[k] = v
+	}
+
+	events := make([]unifi.Event, 0, len(es.recent))
+	for i := 1; i <= len(es.recent); i++ {
+		events = append(events, es.recent[(es.next-i+len(es.recent))%len(es.recent)])
+	}
+
+	return counts, events
+}
+
+// startStreams starts an event stream for each configured site. Streams
+// of prev (if not nil) are taken over, if they use the same client.
+func (cfg *Config) startStreams(prev *Config) {
+	cfg.streams = make(map[streamKey]*eventStream)
+
+	for _, ctrl := range cfg.Controllers {
+		target := ctrl.TargetName()
+		client := cfg.clients[target]
+
+		for _, site := range ctrl.EventSites {
+			key := streamKey{target, site}
+			if prev != nil {
+				if es := prev.streams[key]; es != nil && es.client == client {
+					cfg.streams[key] = es
+					continue
+				}
+			}
+
+			es := newEventStream(client, site)
+			cfg.streams[key] = es
+			es.start()
+		}
+	}
+}
+
+// stopStreams stops all streams, which were not taken over by next.
+func (cfg *Config) stopStreams(next *Config) {
+	for key, es := range cfg.streams {
+		if next == nil || next.streams[key] != es {
+			es.stop()
+		}
+	}
+}
+
+// targetStreams returns the event streams of a target.
+func (cfg *Config) targetStreams(target string) []*eventStream {
+	var streams []*eventStream
+	for key, es := range cfg.streams {
+		if key.target == target {
+			streams = append(streams, es)
+		}
+	}
+
+	// stable order, as the collector only uses the first stream of a site
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].site < streams[j].site
+	})
+	return streams
+}
+
+func (cfg *Config) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	key := streamKey{
+		target: r.URL.Query().Get("target"),
+		site:   r.URL.Query().Get("site"),
+	}
+
+	es := cfg.streams[key]
+	if es == nil {
+		http.Error(w, "event stream not found", http.StatusNotFound)
+		return
+	}
+
+	counts, events := es.snapshot()
+
+	type count struct {
+		Key, Subsystem string
+		Count          uint64
+	}
+	vars := struct {
+		Target, Site string
+		Counts       []count
+		Events       []unifi.Event
+	}{
+		Target: key.target,
+		Site:   key.site,
+		Events: events,
+	}
+
+	for k, v := range counts {
+		vars.Counts = append(vars.Counts, count{k.key, k.subsystem, v})
+	}
+	sort.Slice(vars.Counts, func(i, j int) bool {
+		return vars.Counts[i].Count > vars.Counts[j].Count
+	})
+
+	eventsTmpl.Execute(w, &vars)
+}
+
+//go:embed events.tpl.html
+var rawEventsTmpl string
+
+var eventsTmpl = template.Must(template.New("events").Option("missingkey=error").Parse(rawEventsTmpl))
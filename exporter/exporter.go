@@ -1,53 +1,120 @@
 package exporter
 
 import (
+	"context"
 	_ "embed" //nolint:golint
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/digineo/unifi-sdn-exporter/unifi"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
-func (cfg *Config) Start(listenAddress, version string) {
-	http.Handle("/metrics", cfg.targetMiddleware(cfg.metricsHandler))
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.RequestURI != "/" {
-			http.NotFound(w, r)
+// Start runs the web server. The optional webConfigFile may enable TLS
+// and authentication, see https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md.
+func (cfg *Config) Start(listenAddress, webConfigFile, version string) {
+	cfg.startPollers(nil)
+	cfg.startStreams(nil)
+
+	srv := &server{}
+	srv.cfg.Store(cfg)
+	srv.watchSignals()
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+
+	selfReg := prometheus.NewRegistry()
+	selfReg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		configReloadSuccess,
+		configReloadSeconds,
+	)
+	unifi.MustRegisterInstrumentation(selfReg)
+	selfHandler := promhttp.HandlerFor(selfReg, promhttp.HandlerOpts{})
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		// without target, we're describing ourself
+		if r.URL.Query().Get("target") == "" {
+			selfHandler.ServeHTTP(w, r)
 			return
 		}
 
-		vars := struct {
-			Version string
-			Sites   map[string][]unifi.Site
-		}{
-			Version: version,
-			Sites:   make(map[string][]unifi.Site),
+		cfg := srv.config()
+		cfg.targetMiddleware(cfg.metricsHandler).ServeHTTP(w, r)
+	})
+	http.HandleFunc("/sd", func(w http.ResponseWriter, r *http.Request) {
+		srv.config().discoveryHandler(w, r)
+	})
+	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		srv.config().eventsHandler(w, r)
+	})
+	http.HandleFunc("/-/reload", srv.reloadHandler)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		srv.config().indexHandler(w, r, version)
+	})
+
+	log.Printf("Starting exporter on %s", listenAddress)
+	httpSrv := &http.Server{ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(web.ListenAndServe(httpSrv, &web.FlagConfig{
+		WebListenAddresses: &[]string{listenAddress},
+		WebSystemdSocket:   new(bool),
+		WebConfigFile:      &webConfigFile,
+	}, slog.Default()))
+}
+
+func (cfg *Config) indexHandler(w http.ResponseWriter, r *http.Request, version string) {
+	if r.RequestURI != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	type stream struct {
+		Target, Site string
+	}
+	vars := struct {
+		Version string
+		Sites   map[string][]unifi.Site
+		Streams []stream
+	}{
+		Version: version,
+		Sites:   make(map[string][]unifi.Site),
+	}
+
+	for key := range cfg.streams {
+		vars.Streams = append(vars.Streams, stream{key.target, key.site})
+	}
+	sort.Slice(vars.Streams, func(i, j int) bool {
+		a, b := vars.Streams[i], vars.Streams[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
 		}
+		return a.Site < b.Site
+	})
 
-		for target, client := range cfg.clients {
-			s, err := client.Sites(r.Context())
-			if err != nil {
-				http.Error(w, fmt.Sprintf("error fetching sites for controller %s: %v", target, err), http.StatusInternalServerError)
-				return
-			}
-
-			sort.Slice(s, func(i, j int) bool {
-				return strings.Compare(s[i].Desc, s[j].Desc) < 0
-			})
-			vars.Sites[target] = s
+	for target, client := range cfg.clients {
+		s, err := client.Sites(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error fetching sites for controller %s: %v", target, err), http.StatusInternalServerError)
+			return
 		}
 
-		tmpl.Execute(w, &vars)
-	})
+		sort.Slice(s, func(i, j int) bool {
+			return strings.Compare(s[i].Desc, s[j].Desc) < 0
+		})
+		vars.Sites[target] = s
+	}
 
-	log.Printf("Starting exporter on http://%s/", listenAddress)
-	log.Fatal(http.ListenAndServe(listenAddress, nil))
+	tmpl.Execute(w, &vars)
 }
 
 type targetHandler func(unifi.Client, string, http.ResponseWriter, *http.Request)
@@ -62,8 +129,7 @@ func (cfg *Config) targetMiddleware(next targetHandler) http.Handler {
 
 		site := r.URL.Query().Get("site")
 		if site == "" {
-			http.Error(w, "site parameter missing", http.StatusBadRequest)
-			return
+			site = allSites
 		}
 
 		client := cfg.clients[target]
@@ -77,16 +143,48 @@ func (cfg *Config) targetMiddleware(next targetHandler) http.Handler {
 }
 
 func (cfg *Config) metricsHandler(client unifi.Client, site string, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if timeout := cfg.scrapeTimeout(r); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(&unifiCollector{
-		client: client,
-		ctx:    r.Context(),
-		site:   site,
+		client:      client,
+		ctx:         ctx,
+		site:        site,
+		parallelism: cfg.SiteParallelism,
+		poller:      cfg.pollers[client.TargetName()],
+		streams:     cfg.targetStreams(client.TargetName()),
 	})
 	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
 	h.ServeHTTP(w, r)
 }
 
+// scrapeTimeout derives the time available for fetching metrics from
+// the timeout Prometheus sends along with the scrape request. It returns
+// 0 if the header is missing or invalid.
+func (cfg *Config) scrapeTimeout(r *http.Request) time.Duration {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("invalid scrape timeout %q: %v", v, err)
+		return 0
+	}
+
+	timeout := time.Duration(seconds*float64(time.Second)) - cfg.ScrapeTimeoutOffset
+	if timeout <= 0 {
+		return 0
+	}
+	return timeout
+}
+
 //go:embed index.tpl.html
 var rawTmpl string
 
@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+)
+
+// poller periodically fetches the metrics of all sites of a controller
+// in the background. The collector then serves the last snapshot.
+type poller struct {
+	client      unifi.Client
+	interval    time.Duration
+	parallelism int
+	cancel      context.CancelFunc
+
+	mu        sync.RWMutex
+	sites     []unifi.Site         // sites found in last poll
+	snapshots map[string]*snapshot // keyed by site name
+	err       error                // error listing sites in last poll
+}
+
+type snapshot struct {
+	metrics     *unifi.Metrics // result of last successful poll
+	err         error          // error of last poll
+	lastSuccess time.Time
+}
+
+func newPoller(client unifi.Client, interval time.Duration, parallelism int) *poller {
+	return &poller{
+		client:      client,
+		interval:    interval,
+		parallelism: parallelism,
+		snapshots:   make(map[string]*snapshot),
+		err:         fmt.Errorf("controller %s not polled yet", client.TargetName()),
+	}
+}
+
+func (p *poller) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.run(ctx)
+}
+
+func (p *poller) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+// run polls until ctx is canceled.
+func (p *poller) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *poller) poll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	sites, err := p.client.Sites(ctx)
+	if err != nil {
+		log.Printf("polling sites of %s failed: %v", p.client.TargetName(), err)
+
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		return
+	}
+
+	results := fetchSites(ctx, p.client, sites, p.parallelism)
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshots := make(map[string]*snapshot, len(results))
+	for _, res := range results {
+		snap := p.snapshots[res.site.Name]
+		if snap == nil {
+			snap = &snapshot{}
+		}
+
+		snap.err = res.err
+		if res.err == nil {
+			snap.metrics = res.metrics
+			snap.lastSuccess = now
+		}
+		snapshots[res.site.Name] = snap
+	}
+
+	p.sites = sites
+	p.snapshots = snapshots
+	p.err = nil
+}
+
+// lastError returns the error of the last poll of the site list, if
+// any. The results are stale in that case.
+func (p *poller) lastError() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.err
+}
+
+// results returns the snapshots for the given site (identified by name
+// or description), or for all sites.
+func (p *poller) results(ident string) ([]siteResult, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.err != nil && len(p.sites) == 0 {
+		return nil, p.err
+	}
+
+	var results []siteResult
+	for _, site := range p.sites {
+		if ident != allSites && ident != site.Name && ident != site.Desc {
+			continue
+		}
+
+		snap := p.snapshots[site.Name]
+		results = append(results, siteResult{
+			site:        site,
+			metrics:     snap.metrics,
+			err:         snap.err,
+			lastSuccess: snap.lastSuccess,
+		})
+	}
+
+	if len(results) == 0 && ident != allSites {
+		return nil, fmt.Errorf("site '%s' not found", ident)
+	}
+	return results, nil
+}
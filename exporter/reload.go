@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "whether the last configuration reload attempt was successful",
+	})
+
+	configReloadSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful configuration reload",
+	})
+)
+
+// server holds the active configuration, which may be swapped on reload.
+type server struct {
+	cfg      atomic.Pointer[Config]
+	reloadMu sync.Mutex // serializes reloads
+}
+
+func (srv *server) config() *Config {
+	return srv.cfg.Load()
+}
+
+// reload loads the config file again. Clients and pollers of unchanged
+// controllers are kept. If the new config is invalid, the active config
+// stays in place.
+func (srv *server) reload() error {
+	srv.reloadMu.Lock()
+	defer srv.reloadMu.Unlock()
+
+	prev := srv.config()
+	next, err := LoadConfig(prev.file)
+	if err != nil {
+		configReloadSuccess.Set(0)
+		return err
+	}
+
+	next.reuseClients(prev)
+	next.startPollers(prev)
+	next.startStreams(prev)
+	srv.cfg.Store(next)
+	prev.stopPollers(next)
+	prev.stopStreams(next)
+
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	log.Printf("reloaded config file %q", next.file)
+
+	return nil
+}
+
+// watchSignals reloads the config on SIGHUP.
+func (srv *server) watchSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			if err := srv.reload(); err != nil {
+				log.Println("reloading config failed:", err)
+			}
+		}
+	}()
+}
+
+func (srv *server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := srv.reload(); err != nil {
+		log.Println("reloading config failed:", err)
+		http.Error(w, fmt.Sprintf("reloading config failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "config reloaded")
+}
+
+// reuseClients replaces the controllers in cfg with those from prev,
+// if their configuration is unchanged. This keeps sessions and caches.
+func (cfg *Config) reuseClients(prev *Config) {
+	for i, ctrl := range cfg.Controllers {
+		for _, old := range prev.Controllers {
+			if sameController(ctrl, old) {
+				cfg.Controllers[i] = old
+				cfg.clients[old.TargetName()] = old
+				break
+			}
+		}
+	}
+}
+
+// sameController compares the exported (i.e. configurable) fields of
+// two controllers.
+func sameController(a, b *unifi.Controller) bool {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if !va.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+)
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} with the value of the environment variable
+// VAR. Unlike os.ExpandEnv, $VAR is left untouched (passwords may well
+// contain a "$"), and unset variables are reported as error.
+func expandEnv(s string) (string, error) {
+	var err error
+	res := envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := match[2 : len(match)-1]
+		val, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return val
+	})
+	return res, err
+}
+
+// readSecret reads a secret from a file, stripping trailing newlines.
+func readSecret(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading secret failed: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveSecrets expands environment variables in the controller
+// settings and reads the password and API key files.
+func resolveSecrets(ctrl *unifi.Controller) (err error) {
+	for _, field := range []*string{
+		&ctrl.URL,
+		&ctrl.Username,
+		&ctrl.Password,
+		&ctrl.APIKey,
+		&ctrl.PasswordFile,
+		&ctrl.APIKeyFile,
+	} {
+		if *field, err = expandEnv(*field); err != nil {
+			return err
+		}
+	}
+
+	if ctrl.PasswordFile != "" {
+		if ctrl.Password != "" {
+			return errors.New("password and password-file are mutually exclusive")
+		}
+		if ctrl.Password, err = readSecret(ctrl.PasswordFile); err != nil {
+			return err
+		}
+	}
+
+	if ctrl.APIKeyFile != "" {
+		if ctrl.APIKey != "" {
+			return errors.New("api-key and api-key-file are mutually exclusive")
+		}
+		if ctrl.APIKey, err = readSecret(ctrl.APIKeyFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"runtime/debug"
+	"time"
 
 	"github.com/digineo/unifi-sdn-exporter/exporter"
 	"github.com/digineo/unifi-sdn-exporter/unifi"
@@ -33,11 +35,26 @@ func main() {
 		"Path to config.toml that contains all the targets.",
 	).Default(DefaultConfigPath).String()
 
+	webConfigFile := kingpin.Flag(
+		"web.config.file",
+		"Path to configuration file that can enable TLS or authentication.",
+	).Default("").String()
+
 	verbose := kingpin.Flag(
 		"verbose",
 		"Increase verbosity",
 	).Bool()
 
+	recordDir := kingpin.Flag(
+		"record-dir",
+		"Directory to store all raw API responses in (for replaying them with controller-type \"replay\").",
+	).Default("").String()
+
+	recordAnonymize := kingpin.Flag(
+		"record-anonymize",
+		"Replace MAC addresses and secrets in recorded API responses.",
+	).Bool()
+
 	kingpin.Flag("version", "Show version information").
 		Short('v').
 		PreAction(func(*kingpin.ParseContext) error {
@@ -47,8 +64,35 @@ func main() {
 		}).
 		Bool()
 
+	kingpin.Command("serve", "Start the exporter (default).").Default()
+
+	backfill := kingpin.Command("backfill", "Write a historical site report in the OpenMetrics format to stdout.")
+	backfillOpts := exporter.BackfillOptions{}
+	backfill.Flag("target", "Controller alias or host name.").Required().StringVar(&backfillOpts.Target)
+	backfill.Flag("site", "Site name or description.").Required().StringVar(&backfillOpts.Site)
+	backfill.Flag("interval", "Report interval.").Default("hourly").EnumVar(&backfillOpts.Interval, unifi.ReportIntervals...)
+	backfill.Flag("type", "Report type.").Default("site").EnumVar(&backfillOpts.Type, unifi.ReportTypes...)
+	backfill.Flag("attr", "Report attribute (repeatable).").Default("bytes", "wan-rx_bytes", "wan-tx_bytes", "num_sta").StringsVar(&backfillOpts.Attrs)
+	backfillRange := backfill.Flag("range", "Time range to fetch, ending now.").Default("168h").Duration()
+
+	checkConfig := kingpin.Command("check-config", "Validate the config file.")
+	checkLogin := checkConfig.Flag("login", "Also try to log in to each controller.").Bool()
+
+	sites := kingpin.Command("sites", "List the sites of each controller.")
+	sitesTarget := sites.Flag("target", "Only list the sites of this controller.").String()
+
+	scrape := kingpin.Command("scrape", "Print the metrics of a target to stdout.")
+	scrapeTarget := scrape.Flag("target", "Controller alias or host name.").Required().String()
+	scrapeSite := scrape.Flag("site", "Site name or description (default: all sites).").String()
+
+	raw := kingpin.Command("raw", "Print the JSON response of an API request to stdout.")
+	rawTarget := raw.Flag("target", "Controller alias or host name.").Required().String()
+	rawData := raw.Flag("data", "JSON request body (only for POST).").String()
+	rawMethod := raw.Arg("method", "HTTP method.").Required().Enum("GET", "POST")
+	rawPath := raw.Arg("path", "API path, e.g. /api/s/default/stat/device").Required().String()
+
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 
 	log.SetFlags(log.Lshortfile)
 	cfg, err := exporter.LoadConfig(*configFile)
@@ -57,7 +101,30 @@ func main() {
 	}
 
 	unifi.Verbose = *verbose
-	cfg.Start(*listenAddress, version)
+	unifi.RecordDir = *recordDir
+	unifi.RecordAnonymize = *recordAnonymize
+
+	ctx := context.Background()
+
+	switch cmd {
+	case backfill.FullCommand():
+		backfillOpts.End = time.Now()
+		backfillOpts.Start = backfillOpts.End.Add(-*backfillRange)
+		err = cfg.Backfill(ctx, os.Stdout, backfillOpts)
+	case checkConfig.FullCommand():
+		err = cfg.CheckConfig(ctx, os.Stdout, *checkLogin)
+	case sites.FullCommand():
+		err = cfg.ListSites(ctx, os.Stdout, *sitesTarget)
+	case scrape.FullCommand():
+		err = cfg.Scrape(ctx, os.Stdout, *scrapeTarget, *scrapeSite)
+	case raw.FullCommand():
+		err = cfg.Raw(ctx, os.Stdout, *rawTarget, *rawMethod, *rawPath, *rawData)
+	default:
+		cfg.Start(*listenAddress, *webConfigFile, version)
+	}
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 }
 
 func printVersion() {
@@ -0,0 +1,126 @@
+package unifi
+
+import (
+	"sort"
+	"time"
+)
+
+// Bounds of the event query in Metrics. Events beyond these bounds
+// are not counted (see Metrics.EventGaps).
+const (
+	eventsWindow = time.Hour // a multiple of hours
+	eventsLimit  = 500
+)
+
+// eventCursor marks the newest event seen in a site.
+type eventCursor struct {
+	time    int64           // in ms
+	ids     map[string]bool // IDs of the events seen at time
+	fetched time.Time       // time of the fetch
+}
+
+func (m *Metrics) addAlarms(alarms []alarmResponse) {
+	type alarmKey struct{ key, mac string }
+	counts := make(map[alarmKey]int)
+
+	for _, a := range alarms {
+		if !a.Archived {
+			counts[alarmKey{a.Key, a.DeviceMAC()}]++
+		}
+	}
+
+	for k, n := range counts {
+		m.Alarms = append(m.Alarms, AlarmMetrics{
+			Key:       k.key,
+			DeviceMAC: k.mac,
+			Count:     n,
+		})
+	}
+	sort.Slice(m.Alarms, func(i, j int) bool {
+		a, b := m.Alarms[i], m.Alarms[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.DeviceMAC < b.DeviceMAC
+	})
+}
+
+// countEvents adds the events newer than the site's cursor to the
+// site's event counters, and returns a copy of the counters. Events
+// returned by the first fetch only initialize the cursor, as they
+// happened before the exporter was started.
+//
+// The events (fetched at now) are bounded by eventsWindow and
+// eventsLimit. If they might not reach back to the cursor, the site's
+// gap counter is incremented, which is returned as well.
+func (c *Controller) countEvents(siteName string, events []eventResponse, now time.Time) (map[string]uint64, uint64) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+
+	if c.eventCursors == nil {
+		c.eventCursors = make(map[string]*eventCursor)
+		c.eventCounts = make(map[string]map[string]uint64)
+		c.eventGaps = make(map[string]uint64)
+	}
+
+	cur := c.eventCursors[siteName]
+	counts := c.eventCounts[siteName]
+	if counts == nil {
+		counts = make(map[string]uint64)
+		c.eventCounts[siteName] = counts
+	}
+
+	if cur != nil && eventsIncomplete(cur, events, now) {
+		c.eventGaps[siteName]++
+	}
+
+	next := &eventCursor{ids: make(map[string]bool), fetched: now}
+	if cur != nil {
+		next.time = cur.time
+		for id := range cur.ids {
+			next.ids[id] = true
+		}
+	}
+
+	for _, ev := range events {
+		if cur != nil && (ev.Time < cur.time || ev.Time == cur.time && cur.ids[ev.ID]) {
+			continue // already seen
+		}
+		if cur != nil {
+			counts[ev.Key]++
+		}
+
+		switch {
+		case ev.Time > next.time:
+			next.time = ev.Time
+			next.ids = map[string]bool{ev.ID: true}
+		case ev.Time == next.time:
+			next.ids[ev.ID] = true
+		}
+	}
+	c.eventCursors[siteName] = next
+
+	result := make(map[string]uint64, len(counts))
+	for key, n := range counts {
+		result[key] = n
+	}
+	return result, c.eventGaps[siteName]
+}
+
+// eventsIncomplete reports whether events newer than the cursor might
+// be missing, because the previous fetch is older than eventsWindow,
+// or because eventsLimit was hit before reaching the cursor.
+func eventsIncomplete(cur *eventCursor, events []eventResponse, now time.Time) bool {
+	if now.Sub(cur.fetched) > eventsWindow {
+		return true
+	}
+	if len(events) < eventsLimit {
+		return false
+	}
+	for _, ev := range events {
+		if ev.Time <= cur.time {
+			return false // reached the cursor
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package unifi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newEvents returns n events, newest first, the newest at newest (in ms).
+func newEvents(n int, newest int64) []eventResponse {
+	events := make([]eventResponse, n)
+	for i := range events {
+		events[i] = eventResponse{
+			ID:   fmt.Sprintf("e%d", newest-int64(i)),
+			Key:  "EVT_AP_Connected",
+			Time: newest - int64(i),
+		}
+	}
+	return events
+}
+
+func TestCountEvents(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+
+	tests := []struct {
+		name   string
+		after  time.Duration // between the fetches
+		events []eventResponse
+
+		wantCount uint64
+		wantGaps  uint64
+	}{
+		{
+			name:      "within bounds",
+			after:     time.Minute,
+			events:    newEvents(3, 1700000060000),
+			wantCount: 3,
+		},
+		{
+			name:      "limit reaching the cursor",
+			after:     time.Minute,
+			events:    append(newEvents(eventsLimit-1, 1700000060000), newEvents(1, 1700000000000)...),
+			wantCount: eventsLimit - 1,
+		},
+		{
+			name:      "limit exceeded",
+			after:     time.Minute,
+			events:    newEvents(eventsLimit, 1700000060000),
+			wantCount: eventsLimit,
+			wantGaps:  1,
+		},
+		{
+			name:      "window exceeded",
+			after:     2 * eventsWindow,
+			events:    newEvents(3, 1700007200000),
+			wantCount: 3,
+			wantGaps:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{}
+
+			// the first fetch only initializes the cursor
+			counts, gaps := c.countEvents("default", newEvents(1, 1700000000000), start)
+			if len(counts) != 0 || gaps != 0 {
+				t.Fatalf("got %v and %d gaps after the first fetch, want none", counts, gaps)
+			}
+
+			counts, gaps = c.countEvents("default", tt.events, start.Add(tt.after))
+			if got := counts["EVT_AP_Connected"]; got != tt.wantCount {
+				t.Errorf("got %d events, want %d", got, tt.wantCount)
+			}
+			if gaps != tt.wantGaps {
+				t.Errorf("got %d gaps, want %d", gaps, tt.wantGaps)
+			}
+		})
+	}
+}
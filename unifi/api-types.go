@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // metaResponse wraps (most) of the API response objects.
@@ -20,9 +22,12 @@ type metaResponse struct {
 	Data *json.RawMessage // usually an array of 1 object, sometimes empty
 }
 
-const loginPath = "/api/login"
+const (
+	loginPath        = "/api/login"
+	unifiOSLoginPath = "/api/auth/login"
+)
 
-// request for /api/login.
+// request for /api/login and /api/auth/login.
 type loginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -56,6 +61,48 @@ type siteHealthResponse struct {
 	} `json:"wifi_score"`
 }
 
+const siteSubsystemsPath = "/api/s/{siteName}/stat/health"
+
+// Fields are populated depending on the subsystem.
+type siteSubsystemResponse struct {
+	Subsystem string `json:"subsystem"` // "wan", "www", "wlan", "lan", "vpn"
+	Status    string `json:"status"`    // "ok", "warning", "error", "unknown"
+
+	NumAdopted      *int `json:"num_adopted"`
+	NumDisconnected *int `json:"num_disconnected"`
+	NumPending      *int `json:"num_pending"`
+	NumDisabled     *int `json:"num_disabled"`
+	NumUser         *int `json:"num_user"`
+	NumGuest        *int `json:"num_guest"`
+	NumIoT          *int `json:"num_iot"`
+
+	// subsystem "wan"
+	WANIP           string `json:"wan_ip"`
+	ISPName         string `json:"isp_name"`
+	ISPOrganization string `json:"isp_organization"`
+	GatewayMAC      string `json:"gw_mac"`
+	GatewayName     string `json:"gw_name"`
+	GatewayVersion  string `json:"gw_version"`
+	GatewaySystem   *struct {
+		Uptime quotedInt `json:"uptime"` // in seconds
+	} `json:"gw_system-stats"`
+
+	// subsystem "www"
+	Latency          *int     `json:"latency"` // in ms
+	Uptime           *int     `json:"uptime"`  // in seconds
+	Drops            *int     `json:"drops"`
+	SpeedtestLastRun int64    `json:"speedtest_lastrun"` // Unix timestamp
+	SpeedtestPing    *int     `json:"speedtest_ping"`    // in ms
+	SpeedtestDown    *float64 `json:"xput_down"`         // in MBit/s
+	SpeedtestUp      *float64 `json:"xput_up"`           // dito
+
+	// subsystem "vpn"
+	RemoteUserEnabled  bool `json:"remote_user_enabled"`
+	RemoteUserActive   *int `json:"remote_user_num_active"`
+	RemoteUserInactive *int `json:"remote_user_num_inactive"`
+	SiteToSiteEnabled  bool `json:"site_to_site_enabled"`
+}
+
 const siteDevicesPath = "/api/s/{siteName}/stat/device"
 
 type DeviceStatus int
@@ -85,8 +132,15 @@ func (ds DeviceStatus) String() string {
 
 type siteDeviceResponse struct {
 	MAC          string       `json:"mac"`
+	Name         string       `json:"name"`    // alias assigned in the controller
+	Type         string       `json:"type"`    // "uap", "usw", "ugw", "udm", "uxg", ...
+	IP           string       `json:"ip"`      // management address
+	Serial       string       `json:"serial"`  // serial number
+	SiteID       string       `json:"site_id"` // refers to sitesResponse.ID
 	Model        string       `json:"model"`   // short letter code
 	Version      string       `json:"version"` // firmware version
+	Upgradable   bool         `json:"upgradable"`
+	UpgradeTo    string       `json:"upgrade_to_firmware"`
 	Adopted      bool         `json:"adopted"`
 	LTS          bool         `json:"model_in_lts"`
 	EOL          bool         `json:"model_in_eol"`
@@ -103,28 +157,272 @@ type siteDeviceResponse struct {
 		Load15 string `json:"loadavg_15"` // dito
 	} `json:"sys_stats,omitempty"`
 
+	// only for gateways
+	WAN1        *deviceWAN                    `json:"wan1"`
+	WAN2        *deviceWAN                    `json:"wan2"`
+	UptimeStats map[string]*deviceUptimeStats `json:"uptime_stats"` // keyed by "WAN", "WAN2"
+	Speedtest   *struct {
+		RunDate  int64       `json:"rundate"` // Unix timestamp
+		Latency  quotedFloat `json:"latency"` // in ms
+		Download quotedFloat `json:"xput_download"`
+		Upload   quotedFloat `json:"xput_upload"`
+	} `json:"speedtest-status,omitempty"`
+
+	SystemStats *struct {
+		CPU quotedFloat `json:"cpu"` // in percent
+		Mem quotedFloat `json:"mem"` // dito
+	} `json:"system-stats,omitempty"`
+
 	Uplink *struct {
+		Name       string `json:"name"` // interface name, e.g. "eth0"
 		Type       string `json:"type"` // "wire", "wireless"
 		FullDuplex bool   `json:"full_duplex"`
 		Speed      int    `json:"speed"` // in MBit/s
 	} `json:"uplink,omitempty"`
 
+	Ports []devicePort `json:"port_table"`
+
+	Radios     []deviceRadio      `json:"radio_table"`
+	RadioStats []deviceRadioStats `json:"radio_table_stats"`
+
 	// Virtual APs
 	VAP []struct {
-		Channel int    `json:"channel"`
-		BSSID   string `json:"bssid"`
-		ESSID   string `json:"essid"`
-		Clients int    `json:"num_sta"`
-		Radio   string `json:"radio"` // "na" (5GHz), "ng" (2.4GHz)
+		Channel   int    `json:"channel"`
+		BSSID     string `json:"bssid"`
+		ESSID     string `json:"essid"`
+		Clients   int    `json:"num_sta"`
+		Radio     string `json:"radio"`      // "na" (5GHz), "ng" (2.4GHz), "6e" (6GHz)
+		RadioName string `json:"radio_name"` // refers to deviceRadio.Name
+		RxPackets int64  `json:"rx_packets"`
+		RxBytes   int64  `json:"rx_bytes"`
+		TxBytes   int64  `json:"tx_bytes"`
+
+		Satisfaction *int `json:"satisfaction"` // -1 without clients
 	} `json:"vap_table"`
 }
 
+// radio configuration.
+type deviceRadio struct {
+	Name         string    `json:"name"` // "wifi0", "wifi1", ...
+	Radio        string    `json:"radio"`
+	ChannelWidth quotedInt `json:"ht"` // in MHz
+}
+
+// current radio state.
+type deviceRadioStats struct {
+	Name         string    `json:"name"`
+	Radio        string    `json:"radio"`
+	Channel      quotedInt `json:"channel"`
+	TxPower      quotedInt `json:"tx_power"` // in dBm
+	CUTotal      int       `json:"cu_total"` // channel utilization in percent
+	CUSelfRx     int       `json:"cu_self_rx"`
+	CUSelfTx     int       `json:"cu_self_tx"`
+	TxPackets    int64     `json:"tx_packets"`
+	TxRetries    int64     `json:"tx_retries"`
+	Clients      int       `json:"num_sta"`
+	Satisfaction *int      `json:"satisfaction"` // -1 if no clients are connected
+}
+
+const siteStationsPath = "/api/s/{siteName}/stat/sta"
+
+type siteStationResponse struct {
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname"` // as reported via DHCP
+	Name     string `json:"name"`     // alias assigned in the controller
+	OUI      string `json:"oui"`      // vendor name
+	IsWired  bool   `json:"is_wired"`
+	APMAC    string `json:"ap_mac"` // only for wireless stations
+	SWMAC    string `json:"sw_mac"`
+	ESSID    string `json:"essid"`
+	Radio    string `json:"radio"`
+	Channel  int    `json:"channel"`
+	VLAN     int    `json:"vlan"`
+	Uptime   int    `json:"uptime"` // in seconds
+
+	RSSI   int `json:"rssi"`
+	Signal int `json:"signal"`  // in dBm
+	Noise  int `json:"noise"`   // in dBm
+	TxRate int `json:"tx_rate"` // in kBit/s
+	RxRate int `json:"rx_rate"` // dito
+
+	TxBytes      int64 `json:"tx_bytes"`
+	RxBytes      int64 `json:"rx_bytes"`
+	WiredTxBytes int64 `json:"wired-tx_bytes"`
+	WiredRxBytes int64 `json:"wired-rx_bytes"`
+
+	Satisfaction *int `json:"satisfaction"` // 0-100, only for wireless stations
+}
+
+type deviceWAN struct {
+	Interface  string `json:"ifname"`
+	IP         string `json:"ip"`
+	Up         bool   `json:"up"`
+	Enabled    bool   `json:"enable"`
+	Speed      int    `json:"speed"` // in MBit/s
+	FullDuplex bool   `json:"full_duplex"`
+	RxBytes    int64  `json:"rx_bytes"`
+	TxBytes    int64  `json:"tx_bytes"`
+}
+
+// availability of a WAN interface.
+type deviceUptimeStats struct {
+	Availability   quotedFloat `json:"availability"`    // in percent
+	LatencyAverage quotedFloat `json:"latency_average"` // in ms
+}
+
+type devicePort struct {
+	Index      int    `json:"port_idx"`
+	Name       string `json:"name"`
+	Up         bool   `json:"up"`
+	Enabled    bool   `json:"enable"`
+	Speed      int    `json:"speed"` // in MBit/s
+	FullDuplex bool   `json:"full_duplex"`
+	STPState   string `json:"stp_state"` // "disabled", "forwarding", ...
+
+	RxBytes   int64 `json:"rx_bytes"`
+	TxBytes   int64 `json:"tx_bytes"`
+	RxPackets int64 `json:"rx_packets"`
+	TxPackets int64 `json:"tx_packets"`
+	RxErrors  int64 `json:"rx_errors"`
+	TxErrors  int64 `json:"tx_errors"`
+	RxDropped int64 `json:"rx_dropped"`
+	TxDropped int64 `json:"tx_dropped"`
+
+	PoE        bool        `json:"port_poe"`    // port is PoE capable
+	PoEMode    string      `json:"poe_mode"`    // "auto", "off", "pasv24", "passthrough"
+	PoEClass   string      `json:"poe_class"`   // e.g. "Class 4"
+	PoEPower   quotedFloat `json:"poe_power"`   // in W
+	PoEVoltage quotedFloat `json:"poe_voltage"` // in V
+	PoECurrent quotedFloat `json:"poe_current"` // in mA
+
+	SFP            bool        `json:"sfp_found"`
+	SFPTemperature quotedFloat `json:"sfp_temperature"` // in Celsius
+	SFPRxPower     quotedFloat `json:"sfp_rxpower"`
+}
+
+const siteNetworkConfPath = "/api/s/{siteName}/rest/networkconf"
+
+type networkConfResponse struct {
+	ID      string `json:"_id"`
+	Name    string `json:"name"`
+	Purpose string `json:"purpose"`  // "corporate", "guest", "wan", "remote-user-vpn", "site-vpn", ...
+	VPNType string `json:"vpn_type"` // only for VPNs, e.g. "ipsec-vpn", "openvpn-vpn"
+	Enabled bool   `json:"enabled"`
+}
+
+const siteWLANConfPath = "/api/s/{siteName}/rest/wlanconf"
+
+type wlanConfResponse struct {
+	ID          string    `json:"_id"`
+	Name        string    `json:"name"` // the SSID
+	Enabled     bool      `json:"enabled"`
+	Security    string    `json:"security"` // "open", "wpapsk", "wpaeap", ...
+	WPAMode     string    `json:"wpa_mode"` // "wpa2", "wpa3", ...
+	VLANEnabled bool      `json:"vlan_enabled"`
+	VLAN        quotedInt `json:"vlan"`
+	Hidden      bool      `json:"hide_ssid"`
+	IsGuest     bool      `json:"is_guest"`
+	Band        string    `json:"wlan_band"` // "both", "2g", "5g" (older controllers)
+
+	Bands []string `json:"wlan_bands"` // e.g. ["2g", "5g", "6g"]
+}
+
+// BandDescription returns the configured bands, e.g. "2g,5g".
+func (w *wlanConfResponse) BandDescription() string {
+	if len(w.Bands) > 0 {
+		return strings.Join(w.Bands, ",")
+	}
+	if w.Band == "both" {
+		return "2g,5g"
+	}
+	return w.Band
+}
+
+const siteReportPath = "/api/s/{siteName}/stat/report/{interval}.{type}"
+
+// request for stat/report, times are Unix timestamps in ms.
+type reportRequest struct {
+	Attrs []string `json:"attrs"`
+	Start int64    `json:"start"`
+	End   int64    `json:"end"`
+}
+
+const siteAlarmsPath = "/api/s/{siteName}/stat/alarm?archived=false"
+
+type alarmResponse struct {
+	ID        string `json:"_id"`
+	Key       string `json:"key"` // e.g. "EVT_GW_WANTransition"
+	Subsystem string `json:"subsystem"`
+	Message   string `json:"msg"`
+	Time      int64  `json:"time"` // Unix timestamp in ms
+	Archived  bool   `json:"archived"`
+
+	// MAC address of the affected device, depending on its type
+	AP string `json:"ap"`
+	GW string `json:"gw"`
+	SW string `json:"sw"`
+}
+
+// DeviceMAC returns the MAC address of the affected device, if any.
+func (a *alarmResponse) DeviceMAC() string {
+	switch {
+	case a.GW != "":
+		return a.GW
+	case a.SW != "":
+		return a.SW
+	default:
+		return a.AP
+	}
+}
+
+// returns the most recent events (eventResponse), newest first.
+const siteEventsPath = "/api/s/{siteName}/stat/event"
+
+// bounds the events returned by siteEventsPath.
+type eventsRequest struct {
+	Sort   string `json:"_sort"`  // "-time" for newest first
+	Within int    `json:"within"` // in hours
+	Limit  int    `json:"_limit"`
+}
+
+const siteEventStreamPath = "/wss/s/{siteName}/events"
+
+// message received from the event stream.
+type eventStreamMessage struct {
+	Meta struct {
+		RC      string `json:"rc"`
+		Message string `json:"message"` // "events", "sta:sync", "device:sync", ...
+	} `json:"meta"`
+
+	Data []json.RawMessage `json:"data"`
+}
+
+type eventResponse struct {
+	ID        string `json:"_id"`
+	Key       string `json:"key"`       // e.g. "EVT_AP_Lost_Contact"
+	Subsystem string `json:"subsystem"` // e.g. "wlan"
+	Message   string `json:"msg"`
+	Time      int64  `json:"time"` // Unix timestamp in ms
+}
+
+func (ev *eventResponse) event() Event {
+	return Event{
+		ID:        ev.ID,
+		Key:       ev.Key,
+		Subsystem: ev.Subsystem,
+		Message:   ev.Message,
+		Time:      time.UnixMilli(ev.Time),
+	}
+}
+
 func Band(radio string) string {
 	switch radio {
 	case "na":
 		return "5"
 	case "ng":
 		return "2.4"
+	case "6e":
+		return "6"
 	default:
 		return radio
 	}
@@ -289,7 +587,7 @@ type quotedInt int
 
 // UnmarshalJSON implements encoding/json.Unmarshaler.
 func (i *quotedInt) UnmarshalJSON(b []byte) error {
-	if bytes.Equal(b, []byte("null")) {
+	if bytes.Equal(b, []byte("null")) || bytes.Equal(b, []byte(`""`)) {
 		return nil
 	}
 
@@ -305,3 +603,25 @@ func (i *quotedInt) UnmarshalJSON(b []byte) error {
 	*i = quotedInt(val)
 	return nil
 }
+
+// quotedFloat is a float wrapped in quotes (see quotedInt).
+type quotedFloat float64
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.
+func (f *quotedFloat) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) || bytes.Equal(b, []byte(`""`)) {
+		return nil
+	}
+
+	if l := len(b); l > 2 && b[0] == '"' && b[l-1] == '"' {
+		b = b[1 : l-1]
+	}
+
+	var val float64
+	if err := json.Unmarshal(b, &val); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	*f = quotedFloat(val)
+	return nil
+}
@@ -3,16 +3,17 @@ package unifi
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,23 +24,64 @@ type Controller struct {
 	Alias    string
 	Username string
 	Password string
+	APIKey   string `toml:"api-key"` // alternative to Username and Password
 	URL      string
+
+	PasswordFile string `toml:"password-file"` // alternative to Password
+	APIKeyFile   string `toml:"api-key-file"`  // alternative to APIKey
+
 	Insecure bool // skip server certificate check if scheme is https, but the certificate is self-signed
 
+	CAFile       string   `toml:"ca-file"`      // PEM encoded CA certificates for verifying the controller
+	ServerName   string   `toml:"server-name"`  // expected name in the server certificate, defaults to URL host
+	ClientCert   string   `toml:"client-cert"`  // PEM encoded client certificate
+	ClientKey    string   `toml:"client-key"`   // PEM encoded key for ClientCert
+	Fingerprints []string `toml:"fingerprints"` // SHA-256 of accepted server certificates (instead of CA verification)
+
+	Type      string `toml:"controller-type"` // one of TypeAuto (default), TypeClassic, TypeUnifiOS or TypeReplay
+	ReplayDir string `toml:"replay-dir"`      // recorded responses, only for TypeReplay
+
+	Timeout        time.Duration `toml:"timeout"`         // for each API request, defaults to 10s
+	ConnectTimeout time.Duration `toml:"connect-timeout"` // for establishing connections, defaults to 5s
+
+	StationMetrics bool     `toml:"station-metrics"` // fetch per-client metrics (beware of high cardinality)
+	EventMetrics   bool     `toml:"event-metrics"`   // fetch active alarms and count recent events
+	EventSites     []string `toml:"event-sites"`     // sites to subscribe to the event stream
+
 	init     bool
 	client   *http.Client
 	endpoint *url.URL
 
+	detected  bool         // whether unifiOS is known
+	unifiOS   bool         // whether the Network application runs on UniFi OS
+	csrfToken string       // only used by UniFi OS
+	sessionMu sync.RWMutex // protects detected, unifiOS and csrfToken
+
+	session uint64     // incremented on each successful login
+	loginMu sync.Mutex // serializes logins, protects session
+
 	sitesCache        []sitesResponse // maps ident to site
 	sitesCacheExpires time.Time       // marks expiry for cache
 	sitesCacheMu      sync.RWMutex    // protects sitesCache and expiry
+
+	eventCursors map[string]*eventCursor      // maps site name to last seen event
+	eventCounts  map[string]map[string]uint64 // maps site name to event counts by key
+	eventGaps    map[string]uint64            // maps site name to fetches with possibly missed events
+	eventsMu     sync.Mutex                   // protects eventCursors, eventCounts and eventGaps
 }
 
 type Client interface {
 	TargetName() string
+	// Metrics fetches the metrics of a site. On error, the metrics
+	// collected so far may be returned (if any).
 	Metrics(ctx context.Context, siteDesc string) (*Metrics, error)
 	Get(ctx context.Context, path string, res interface{}) error
+	Post(ctx context.Context, path string, req, res interface{}) error
 	Sites(ctx context.Context) ([]Site, error)
+	// Report fetches a historical report (see ReportIntervals and
+	// ReportTypes) of a site for the given time range.
+	Report(ctx context.Context, siteDesc, interval, typ string, attrs []string, start, end time.Time) ([]ReportEntry, error)
+	SubscribeEvents(ctx context.Context, siteDesc string, fn func(Event))
 }
 
 var _ Client = (*Controller)(nil)
@@ -59,9 +101,22 @@ func vlog(s string) {
 	}
 }
 
+// Controller types.
 const (
-	sessionCookieName = "unifises"
-	siteCacheTTL      = 5 * time.Minute
+	TypeAuto    = "auto"     // detect type on first request
+	TypeClassic = "classic"  // self-hosted Network application
+	TypeUnifiOS = "unifi-os" // UDM, UDR, Cloud Key Gen2+, etc.
+	TypeReplay  = "replay"   // serve responses from ReplayDir (see RecordDir)
+)
+
+const (
+	sessionCookieName        = "unifises"
+	unifiOSSessionCookieName = "TOKEN"
+	unifiOSPathPrefix        = "/proxy/network"
+	siteCacheTTL             = 5 * time.Minute
+
+	defaultTimeout        = 10 * time.Second
+	defaultConnectTimeout = 5 * time.Second
 )
 
 // NewClient creates a new Client instance.
@@ -70,26 +125,62 @@ func NewClient(c *Controller) (Client, error) {
 		return c, nil
 	}
 
-	if c.Username == "" || c.Password == "" {
+	if c.Type == TypeReplay {
+		if c.ReplayDir == "" {
+			return nil, ErrMissingReplayDir
+		}
+	} else if c.APIKey == "" && (c.Username == "" || c.Password == "") {
 		return nil, ErrMissingCredentials
 	}
 
+	switch c.Type {
+	case "":
+		c.Type = TypeAuto
+	case TypeAuto:
+		// nothing to do
+	case TypeClassic, TypeUnifiOS, TypeReplay:
+		c.detected = true
+		c.unifiOS = c.Type == TypeUnifiOS
+	default:
+		return nil, &ErrInvalidType{c.Type}
+	}
+
 	endpoint, err := url.Parse(c.URL)
 	if err != nil {
 		return nil, &ErrInvalidEndpoint{err}
 	}
 	c.endpoint = endpoint
 
-	c.client = &http.Client{Timeout: 10 * time.Second}
-	c.client.Jar, _ = cookiejar.New(nil) // error is always nil
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = defaultConnectTimeout
+	}
 
+	dialer := &net.Dialer{
+		Timeout:   c.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		// honors HTTPS_PROXY and NO_PROXY, for API requests as well as
+		// for the event stream (see dialEvents)
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: c.ConnectTimeout,
+		IdleConnTimeout:     90 * time.Second,
+	}
 	if c.endpoint.Scheme == "https" {
-		c.client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: c.Insecure,
-			},
+		if transport.TLSClientConfig, err = c.tlsConfig(); err != nil {
+			return nil, &ErrInvalidTLSConfig{err}
 		}
 	}
+
+	c.client = &http.Client{
+		Timeout:   c.Timeout,
+		Transport: transport,
+	}
+	c.client.Jar, _ = cookiejar.New(nil) // error is always nil
 	c.init = true
 
 	return c, nil
@@ -99,33 +190,129 @@ func (c *Controller) TargetName() string {
 	if c.Alias != "" {
 		return c.Alias
 	}
+	if c.Type == TypeReplay && c.endpoint.Host == "" {
+		return filepath.Base(c.ReplayDir)
+	}
 
 	return c.endpoint.Host
 }
 
+func (c *Controller) isUnifiOS() bool {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	return c.unifiOS
+}
+
+// detectType probes the root URL of the controller, unless the type
+// is already known. UniFi OS consoles respond with 200 OK, while classic
+// controllers redirect to /manage.
+func (c *Controller) detectType(ctx context.Context) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.detected {
+		return nil
+	}
+
+	url := fmt.Sprintf("%s://%s/", c.endpoint.Scheme, c.endpoint.Host)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("cannot construct request: %w", err)
+	}
+
+	client := *c.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("detecting controller type failed: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	c.unifiOS = res.StatusCode == http.StatusOK
+	c.detected = true
+	vlogf("detected UniFi OS: %t", c.unifiOS)
+
+	return nil
+}
+
+// sessionID returns the current session generation, to be passed to
+// relogin when a request using that session was rejected.
+func (c *Controller) sessionID() uint64 {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	return c.session
+}
+
+// relogin logs in again, unless another request already did so since
+// the session with the given ID was obtained. Concurrent logins would
+// invalidate each other's cookie and CSRF token.
+func (c *Controller) relogin(ctx context.Context, id uint64) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if c.session != id {
+		vlog("session already renewed")
+		return nil
+	}
+
+	vlog("unauthorized, logging in")
+	if err := c.login(ctx); err != nil {
+		return err
+	}
+	c.session++
+	return nil
+}
+
+// login creates a new session. Use relogin instead, which prevents
+// concurrent logins.
 func (c *Controller) login(ctx context.Context) error {
+	unifiOS := c.isUnifiOS()
+
+	cookieName := sessionCookieName
+	if unifiOS {
+		cookieName = unifiOSSessionCookieName
+	}
 	c.client.Jar.SetCookies(c.endpoint, []*http.Cookie{{
-		Name:   sessionCookieName,
+		Name:   cookieName,
 		MaxAge: -1,
 	}})
 
+	c.sessionMu.Lock()
+	c.csrfToken = ""
+	c.sessionMu.Unlock()
+
 	req := loginRequest{
 		Username: c.Username,
 		Password: c.Password,
 		Remember: true,
 		Strict:   false,
 	}
-	err := c.apiRequest(ctx, http.MethodPost, loginPath, &req, nil)
+
+	var err error
+	if unifiOS {
+		// UniFi OS responds with a user object instead of a metaResponse
+		_, err = c.doRequest(ctx, http.MethodPost, unifiOSLoginPath, &req)
+	} else {
+		err = c.apiRequest(ctx, http.MethodPost, loginPath, &req, nil)
+	}
+
 	if err != nil {
+		logins.WithLabelValues(c.TargetName(), "failure").Inc()
 		return err
 	}
 
+	logins.WithLabelValues(c.TargetName(), "success").Inc()
 	return nil
 }
 
 // apiRequest sends an API request to the controller. The path is constructed
-// from c.endpoint + path. The request parameter, if not nil, will be JSON
-// encoded, and the JSON response is decoded into the response parameter.
+// from c.endpoint + path (with an additional prefix for UniFi OS consoles).
+// The request parameter, if not nil, will be JSON encoded, and the JSON
+// response is decoded into the response parameter.
 //
 //	req, res := requestType{...}, responseType{...}
 //	err := c.apiRequest(ctx, "POST", "node/status", &req, &res)
@@ -133,6 +320,54 @@ func (c *Controller) login(ctx context.Context) error {
 // If the response parameter is of type *metaResponse, it will be returned
 // still wrapped. Otherwise an implicit metaResponse is unwrapped.
 func (c *Controller) apiRequest(ctx context.Context, method, path string, request, response interface{}) error {
+	path = "/" + strings.TrimPrefix(path, "/")
+	if c.isUnifiOS() {
+		path = unifiOSPathPrefix + path
+	}
+
+	jsonData, err := c.doRequest(ctx, method, path, request)
+	if err != nil {
+		return err
+	}
+
+	// parse response
+	var meta metaResponse
+	if err = json.Unmarshal(jsonData, &meta); err != nil {
+		apiDecodeFailures.WithLabelValues(c.TargetName(), pathTemplate(path)).Inc()
+		return fmt.Errorf("decoding meta response failed: %w", err)
+	}
+	if meta.Meta.RC != "ok" {
+		apiRequestFailures.WithLabelValues(c.TargetName(), pathTemplate(path), meta.Meta.Message).Inc()
+		return ErrRequestFailed(meta.Meta.Message)
+	}
+	if meta.Data == nil {
+		return &genericError{msg: "missing response payload"}
+	}
+
+	// caller wants metaResponse
+	if m, ok := response.(*metaResponse); ok {
+		*m = meta
+		return nil
+	}
+
+	err = json.Unmarshal(*meta.Data, &response)
+	if err != nil {
+		apiDecodeFailures.WithLabelValues(c.TargetName(), pathTemplate(path)).Inc()
+		vlog(string(jsonData))
+		vlog(err.Error())
+		return fmt.Errorf("decoding response failed: %w", err)
+	}
+
+	return nil
+}
+
+// doRequest performs the actual HTTP request and returns the response body.
+// Unlike apiRequest, the path is used as is.
+func (c *Controller) doRequest(ctx context.Context, method, path string, request interface{}) ([]byte, error) {
+	if c.Type == TypeReplay {
+		return c.replay(method, path)
+	}
+
 	url := fmt.Sprintf("%s://%s/%s", c.endpoint.Scheme, c.endpoint.Host, strings.TrimPrefix(path, "/"))
 	vlogf("%s %s", method, url)
 
@@ -140,14 +375,14 @@ func (c *Controller) apiRequest(ctx context.Context, method, path string, reques
 	if request != nil {
 		var buf bytes.Buffer
 		if err := json.NewEncoder(&buf).Encode(request); err != nil {
-			return fmt.Errorf("encoding body failed: %w", err)
+			return nil, fmt.Errorf("encoding body failed: %w", err)
 		}
 		body = &buf
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return fmt.Errorf("cannot construct request: %w", err)
+		return nil, fmt.Errorf("cannot construct request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -155,16 +390,39 @@ func (c *Controller) apiRequest(ctx context.Context, method, path string, reques
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	if c.APIKey != "" {
+		req.Header.Set("X-API-KEY", c.APIKey)
+	}
+
+	c.sessionMu.RLock()
+	if c.csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", c.csrfToken)
+	}
+	c.sessionMu.RUnlock()
+
+	start := time.Now()
 	res, err := c.client.Do(req)
+	apiDuration.WithLabelValues(c.TargetName(), pathTemplate(path)).Observe(time.Since(start).Seconds())
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer res.Body.Close()
 
+	apiResponses.WithLabelValues(c.TargetName(), pathTemplate(path), strconv.Itoa(res.StatusCode)).Inc()
+
+	// UniFi OS hands out a CSRF token on login, and may rotate it later on
+	for _, h := range []string{"X-CSRF-Token", "X-Updated-CSRF-Token"} {
+		if token := res.Header.Get(h); token != "" {
+			c.sessionMu.Lock()
+			c.csrfToken = token
+			c.sessionMu.Unlock()
+		}
+	}
+
 	if res.StatusCode != http.StatusOK {
 		data, _ := ioutil.ReadAll(res.Body)
 
-		return &ErrUnexpectedStatus{
+		return nil, &ErrUnexpectedStatus{
 			Method: method,
 			URL:    url,
 			Status: res.StatusCode,
@@ -174,47 +432,41 @@ func (c *Controller) apiRequest(ctx context.Context, method, path string, reques
 
 	jsonData, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("reading response failed: %w", err)
+		return nil, fmt.Errorf("reading response failed: %w", err)
 	}
 
-	// parse response
-	var meta metaResponse
-	if err = json.Unmarshal(jsonData, &meta); err != nil {
-		return fmt.Errorf("decoding meta response failed: %w", err)
-	}
-	if meta.Meta.RC != "ok" {
-		return ErrRequestFailed(meta.Meta.Message)
-	}
-	if meta.Data == nil {
-		return &genericError{msg: "missing response payload"}
+	if RecordDir != "" && path != loginPath && path != unifiOSLoginPath {
+		c.record(path, jsonData)
 	}
 
-	// caller wants metaResponse
-	if m, ok := response.(*metaResponse); ok {
-		*m = meta
-		return nil
-	}
+	return jsonData, nil
+}
 
-	err = json.Unmarshal(*meta.Data, &response)
-	if err != nil {
-		vlog(string(jsonData))
-		vlog(err.Error())
-		return fmt.Errorf("decoding response failed: %w", err)
-	}
+func (c *Controller) Get(ctx context.Context, path string, res interface{}) error {
+	return c.request(ctx, http.MethodGet, path, nil, res)
+}
 
-	return nil
+// Post sends req as JSON to the API and decodes the response into res.
+func (c *Controller) Post(ctx context.Context, path string, req, res interface{}) error {
+	return c.request(ctx, http.MethodPost, path, req, res)
 }
 
-func (c *Controller) Get(ctx context.Context, path string, res interface{}) error {
+// request performs an API request, and logs in again on expired sessions.
+func (c *Controller) request(ctx context.Context, method, path string, req, res interface{}) error {
+	if err := c.detectType(ctx); err != nil {
+		return err
+	}
+
 	retried := false
 
 retry:
+	session := c.sessionID()
 	errStatus := &ErrUnexpectedStatus{}
-	err := c.apiRequest(ctx, http.MethodGet, path, nil, res)
+	err := c.apiRequest(ctx, method, path, req, res)
 
-	if errors.As(err, &errStatus) && errStatus.Status == http.StatusUnauthorized && !retried {
-		vlog("unauthorized, logging in")
-		err = c.login(ctx)
+	// API keys don't need a session
+	if c.APIKey == "" && errors.As(err, &errStatus) && errStatus.Status == http.StatusUnauthorized && !retried {
+		err = c.relogin(ctx, session)
 		if err == nil {
 			retried = true
 			goto retry
@@ -231,7 +483,7 @@ type Site struct {
 }
 
 func (c *Controller) Sites(ctx context.Context) (sites []Site, err error) {
-	if c.sitesCacheExpires.Before(time.Now().Add(-siteCacheTTL)) {
+	if c.sitesCacheExpired() {
 		if err := c.updateSiteCache(ctx); err != nil {
 			return nil, err
 		}
@@ -247,7 +499,7 @@ func (c *Controller) Sites(ctx context.Context) (sites []Site, err error) {
 }
 
 func (c *Controller) fetchSite(ctx context.Context, ident string) (*sitesResponse, error) {
-	if c.sitesCacheExpires.Before(time.Now().Add(-siteCacheTTL)) {
+	if c.sitesCacheExpired() {
 		if err := c.updateSiteCache(ctx); err != nil {
 			return nil, err
 		}
@@ -265,6 +517,13 @@ func (c *Controller) fetchSite(ctx context.Context, ident string) (*sitesRespons
 	return nil, &ErrSiteNotFound{ident}
 }
 
+func (c *Controller) sitesCacheExpired() bool {
+	c.sitesCacheMu.RLock()
+	defer c.sitesCacheMu.RUnlock()
+
+	return c.sitesCacheExpires.Before(time.Now().Add(-siteCacheTTL))
+}
+
 func (c *Controller) updateSiteCache(ctx context.Context) error {
 	c.sitesCacheMu.Lock()
 	defer c.sitesCacheMu.Unlock()
@@ -295,31 +554,34 @@ func (c *Controller) Metrics(ctx context.Context, siteDesc string) (*Metrics, er
 		return nil, err
 	}
 
+	m := &Metrics{
+		ControllerVersion: status.Meta.ServerVersion,
+		Site:              Site{site.Name, site.Desc},
+	}
+
 	vlog("fetching health info")
 	health := []siteHealthResponse{}
 	if err := c.Get(ctx, sitepath(siteHealthPath), &health); err != nil {
-		return nil, err
+		return m, err
 	}
 	if len(health) != 1 {
-		return nil, &genericError{"unexpected result length"}
+		return m, &genericError{"unexpected result length"}
 	}
 
+	util := health[0].AvgWifiUtilization
+	score := health[0].WifiScore
+	m.AvgWifiUtilization24 = util.Band24
+	m.AvgWifiUtilization50 = util.Band5
+	m.AvgWifiScore = score.ClientScoreAvg
+	m.ClientsPoorScore = score.PoorClients
+	m.ClientsFairScore = score.FairClients
+	m.ClientsGoodScore = score.TotalClients - (score.PoorClients + score.FairClients)
+	m.Fetched |= SectionHealth
+
 	vlog("fetching device statistics")
 	devices := []siteDeviceResponse{}
 	if err := c.Get(ctx, sitepath(siteDevicesPath), &devices); err != nil {
-		return nil, err
-	}
-
-	util := health[0].AvgWifiUtilization
-	score := health[0].WifiScore
-	m := &Metrics{
-		ControllerVersion:    status.Meta.ServerVersion,
-		AvgWifiUtilization24: util.Band24,
-		AvgWifiUtilization50: util.Band5,
-		AvgWifiScore:         score.ClientScoreAvg,
-		ClientsPoorScore:     score.PoorClients,
-		ClientsFairScore:     score.FairClients,
-		ClientsGoodScore:     score.TotalClients - (score.PoorClients + score.FairClients),
+		return m, err
 	}
 
 	for _, d := range devices {
@@ -329,11 +591,18 @@ func (c *Controller) Metrics(ctx context.Context, siteDesc string) (*Metrics, er
 
 		dm := DeviceMetrics{
 			MAC:         d.MAC,
+			Name:        d.Name,
+			Type:        d.Type,
+			IP:          d.IP,
+			Serial:      d.Serial,
+			SiteID:      d.SiteID,
 			Firmware:    d.Version,
 			Model:       d.Model,
 			ModelHuman:  d.ModelHuman(),
 			LTS:         d.LTS,
 			EOL:         d.EOL,
+			Upgradable:  d.Upgradable,
+			UpgradeTo:   d.UpgradeTo,
 			Status:      int(d.State),
 			StatusHuman: d.State.String(),
 			Radios:      make(map[string]int),
@@ -364,12 +633,345 @@ func (c *Controller) Metrics(ctx context.Context, siteDesc string) (*Metrics, er
 			}
 		}
 
+		if sys := d.SystemStats; sys != nil {
+			cpu, mem := float64(sys.CPU), float64(sys.Mem)
+			dm.CPU = &cpu
+			dm.Memory = &mem
+		}
+
+		uplink := ""
+		if d.Uplink != nil {
+			uplink = d.Uplink.Name
+		}
+		dm.addWAN("wan1", d.WAN1, d.UptimeStats["WAN"], uplink)
+		dm.addWAN("wan2", d.WAN2, d.UptimeStats["WAN2"], uplink)
+		if st := d.Speedtest; st != nil && st.RunDate > 0 {
+			dm.Speedtest = &SpeedtestMetrics{
+				LastRun:  time.Unix(st.RunDate, 0),
+				Latency:  float64(st.Latency),
+				Download: float64(st.Download),
+				Upload:   float64(st.Upload),
+			}
+		}
+
 		for _, vap := range d.VAP {
 			dm.Radios[Band(vap.Radio)] += vap.Clients
+
+			vm := VAPMetrics{
+				ESSID:   vap.ESSID,
+				BSSID:   vap.BSSID,
+				Radio:   vap.RadioName,
+				Band:    Band(vap.Radio),
+				Clients: vap.Clients,
+				RxBytes: vap.RxBytes,
+				TxBytes: vap.TxBytes,
+			}
+			if vap.Satisfaction != nil && *vap.Satisfaction >= 0 {
+				vm.Satisfaction = vap.Satisfaction
+			}
+			dm.VAPs = append(dm.VAPs, vm)
+		}
+
+		for _, r := range d.RadioStats {
+			rm := RadioMetrics{
+				Name:      r.Name,
+				Band:      Band(r.Radio),
+				Channel:   int(r.Channel),
+				TxPower:   int(r.TxPower),
+				Clients:   r.Clients,
+				CUTotal:   r.CUTotal,
+				CUSelfRx:  r.CUSelfRx,
+				CUSelfTx:  r.CUSelfTx,
+				TxPackets: r.TxPackets,
+				TxRetries: r.TxRetries,
+			}
+			if i := r.CUTotal - r.CUSelfRx - r.CUSelfTx; i > 0 {
+				rm.Interference = i
+			}
+			if r.Satisfaction != nil && *r.Satisfaction >= 0 {
+				rm.Satisfaction = r.Satisfaction
+			}
+			for _, conf := range d.Radios {
+				if conf.Name == r.Name {
+					rm.ChannelWidth = int(conf.ChannelWidth)
+				}
+			}
+			for _, vap := range d.VAP {
+				if vap.RadioName == r.Name {
+					rm.RxPackets += vap.RxPackets
+				}
+			}
+			dm.RadioStats = append(dm.RadioStats, rm)
+		}
+
+		for _, p := range d.Ports {
+			pm := PortMetrics{
+				Index:      p.Index,
+				Name:       p.Name,
+				Up:         p.Up,
+				Enabled:    p.Enabled,
+				Speed:      p.Speed,
+				FullDuplex: p.FullDuplex,
+				STPState:   p.STPState,
+				RxBytes:    p.RxBytes,
+				TxBytes:    p.TxBytes,
+				RxPackets:  p.RxPackets,
+				TxPackets:  p.TxPackets,
+				RxErrors:   p.RxErrors,
+				TxErrors:   p.TxErrors,
+				RxDropped:  p.RxDropped,
+				TxDropped:  p.TxDropped,
+			}
+			if p.PoE {
+				pm.PoE = &PoEMetrics{
+					Mode:    p.PoEMode,
+					Class:   p.PoEClass,
+					Power:   float64(p.PoEPower),
+					Voltage: float64(p.PoEVoltage),
+					Current: float64(p.PoECurrent),
+				}
+			}
+			if p.SFP {
+				pm.SFP = &SFPMetrics{
+					Temperature: float64(p.SFPTemperature),
+					RxPower:     float64(p.SFPRxPower),
+				}
+			}
+			dm.Ports = append(dm.Ports, pm)
 		}
 
 		m.Devices = append(m.Devices, dm)
 	}
+	m.addSSIDs()
+	m.Fetched |= SectionDevices
+
+	// Subsystem health and network configuration are supplementary,
+	// failing to fetch them doesn't discard the site's other metrics.
+	vlog("fetching subsystem health")
+	subsystems := []siteSubsystemResponse{}
+	if err := c.Get(ctx, sitepath(siteSubsystemsPath), &subsystems); err != nil {
+		if ctx.Err() != nil {
+			return m, err
+		}
+		log.Printf("fetching subsystem health of site %q failed: %v", site.Name, err)
+	} else {
+		for _, sub := range subsystems {
+			m.addSubsystem(&sub)
+		}
+		m.Fetched |= SectionSubsystems
+	}
+
+	vlog("fetching network configuration")
+	networks := []networkConfResponse{}
+	if err := c.Get(ctx, sitepath(siteNetworkConfPath), &networks); err != nil {
+		if ctx.Err() != nil {
+			return m, err
+		}
+		log.Printf("fetching network configuration of site %q failed: %v", site.Name, err)
+	} else {
+		for _, n := range networks {
+			if n.Purpose == "site-vpn" {
+				m.SiteToSiteTunnels = append(m.SiteToSiteTunnels, VPNTunnelMetrics{
+					Name:    n.Name,
+					Type:    n.VPNType,
+					Enabled: n.Enabled,
+				})
+			}
+		}
+		m.Fetched |= SectionNetworks
+	}
+
+	vlog("fetching WLAN configuration")
+	wlans := []wlanConfResponse{}
+	if err := c.Get(ctx, sitepath(siteWLANConfPath), &wlans); err != nil {
+		return m, err
+	}
+	for _, w := range wlans {
+		wm := WLANMetrics{
+			ID:       w.ID,
+			ESSID:    w.Name,
+			Enabled:  w.Enabled,
+			Security: w.Security,
+			Bands:    w.BandDescription(),
+			Hidden:   w.Hidden,
+			Guest:    w.IsGuest,
+		}
+		if w.WPAMode != "" && w.Security != "open" {
+			wm.Security += "/" + w.WPAMode
+		}
+		if w.VLANEnabled {
+			wm.VLAN = int(w.VLAN)
+		}
+		m.WLANs = append(m.WLANs, wm)
+	}
+	m.Fetched |= SectionWLANs
+
+	if c.StationMetrics {
+		vlog("fetching station statistics")
+		stations := []siteStationResponse{}
+		if err := c.Get(ctx, sitepath(siteStationsPath), &stations); err != nil {
+			return m, err
+		}
+
+		for _, sta := range stations {
+			sm := StationMetrics{
+				MAC:          sta.MAC,
+				Hostname:     sta.Hostname,
+				Name:         sta.Name,
+				OUI:          sta.OUI,
+				Wired:        sta.IsWired,
+				SWMAC:        sta.SWMAC,
+				VLAN:         sta.VLAN,
+				TxBytes:      sta.TxBytes,
+				RxBytes:      sta.RxBytes,
+				Uptime:       time.Duration(sta.Uptime) * time.Second, //nolint:durationcheck
+				Satisfaction: sta.Satisfaction,
+			}
+
+			if sta.IsWired {
+				sm.TxBytes = sta.WiredTxBytes
+				sm.RxBytes = sta.WiredRxBytes
+			} else {
+				sm.APMAC = sta.APMAC
+				sm.ESSID = sta.ESSID
+				sm.Band = Band(sta.Radio)
+				sm.Channel = sta.Channel
+				sm.RSSI = sta.RSSI
+				sm.Signal = sta.Signal
+				sm.Noise = sta.Noise
+				sm.TxRate = sta.TxRate
+				sm.RxRate = sta.RxRate
+			}
+
+			m.Stations = append(m.Stations, sm)
+		}
+		m.Fetched |= SectionStations
+	}
+
+	if c.EventMetrics {
+		vlog("fetching alarms")
+		alarms := []alarmResponse{}
+		if err := c.Get(ctx, sitepath(siteAlarmsPath), &alarms); err != nil {
+			return m, err
+		}
+		m.addAlarms(alarms)
+		m.Fetched |= SectionAlarms
+
+		vlog("fetching events")
+		req := eventsRequest{
+			Sort:   "-time",
+			Within: int(eventsWindow / time.Hour),
+			Limit:  eventsLimit,
+		}
+		events := []eventResponse{}
+		if err := c.Post(ctx, sitepath(siteEventsPath), &req, &events); err != nil {
+			return m, err
+		}
+		m.Events, m.EventGaps = c.countEvents(site.Name, events, time.Now())
+		m.Fetched |= SectionEvents
+	}
 
 	return m, nil
 }
+
+// addSSIDs sums up the virtual APs of all devices by SSID.
+func (m *Metrics) addSSIDs() {
+	index := make(map[string]int) // maps ESSID to index in m.SSIDs
+
+	for _, d := range m.Devices {
+		for _, vap := range d.VAPs {
+			i, ok := index[vap.ESSID]
+			if !ok {
+				i = len(m.SSIDs)
+				index[vap.ESSID] = i
+				m.SSIDs = append(m.SSIDs, SSIDMetrics{ESSID: vap.ESSID})
+			}
+
+			ssid := &m.SSIDs[i]
+			ssid.Clients += vap.Clients
+			ssid.RxBytes += vap.RxBytes
+			ssid.TxBytes += vap.TxBytes
+		}
+	}
+}
+
+func (m *Metrics) addSubsystem(sub *siteSubsystemResponse) {
+	m.Subsystems = append(m.Subsystems, SubsystemMetrics{
+		Name:         sub.Subsystem,
+		Status:       sub.Status,
+		Adopted:      sub.NumAdopted,
+		Disconnected: sub.NumDisconnected,
+		Pending:      sub.NumPending,
+		Disabled:     sub.NumDisabled,
+		Users:        sub.NumUser,
+		Guests:       sub.NumGuest,
+		IoT:          sub.NumIoT,
+	})
+
+	switch sub.Subsystem {
+	case "wan":
+		m.WAN = &WANHealth{
+			IP:              sub.WANIP,
+			ISPName:         sub.ISPName,
+			ISPOrganization: sub.ISPOrganization,
+			GatewayMAC:      sub.GatewayMAC,
+			GatewayName:     sub.GatewayName,
+			GatewayVersion:  sub.GatewayVersion,
+		}
+		if sys := sub.GatewaySystem; sys != nil {
+			uptime := time.Duration(sys.Uptime) * time.Second //nolint:durationcheck
+			m.WAN.GatewayUptime = &uptime
+		}
+
+	case "www":
+		m.WWW = &WWWHealth{
+			Latency:       sub.Latency,
+			Drops:         sub.Drops,
+			SpeedtestPing: sub.SpeedtestPing,
+			SpeedtestDown: sub.SpeedtestDown,
+			SpeedtestUp:   sub.SpeedtestUp,
+		}
+		if sub.Uptime != nil {
+			uptime := time.Duration(*sub.Uptime) * time.Second //nolint:durationcheck
+			m.WWW.Uptime = &uptime
+		}
+		if sub.SpeedtestLastRun > 0 {
+			m.WWW.SpeedtestLastRun = time.Unix(sub.SpeedtestLastRun, 0)
+		}
+
+	case "vpn":
+		m.VPN = &VPNHealth{
+			RemoteUserEnabled:  sub.RemoteUserEnabled,
+			RemoteUserActive:   sub.RemoteUserActive,
+			RemoteUserInactive: sub.RemoteUserInactive,
+			SiteToSiteEnabled:  sub.SiteToSiteEnabled,
+		}
+	}
+}
+
+// addWAN adds the WAN interface, if present. The stats may be nil, and
+// uplink is the interface name of the device's current uplink.
+func (dm *DeviceMetrics) addWAN(name string, wan *deviceWAN, stats *deviceUptimeStats, uplink string) {
+	if wan == nil || wan.Interface == "" {
+		return
+	}
+
+	wm := WANMetrics{
+		Name:      name,
+		Interface: wan.Interface,
+		IP:        wan.IP,
+		Up:        wan.Up,
+		Enabled:   wan.Enabled,
+		Active:    uplink == wan.Interface,
+		Speed:     wan.Speed,
+		RxBytes:   wan.RxBytes,
+		TxBytes:   wan.TxBytes,
+	}
+	if stats != nil {
+		latency, avail := float64(stats.LatencyAverage), float64(stats.Availability)
+		wm.Latency = &latency
+		wm.Availability = &avail
+	}
+
+	dm.WANs = append(dm.WANs, wm)
+}
@@ -0,0 +1,387 @@
+package unifi_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digineo/unifi-sdn-exporter/unifi"
+	"github.com/digineo/unifi-sdn-exporter/unifi/fakecontroller"
+)
+
+const devicesPath = "/api/s/default/stat/device"
+
+func newClient(t *testing.T, srv *fakecontroller.Server, apiKey bool, timeout time.Duration) unifi.Client {
+	t.Helper()
+
+	ctrl := &unifi.Controller{
+		URL:     srv.URL,
+		Timeout: timeout,
+	}
+	if apiKey {
+		ctrl.APIKey = fakecontroller.APIKey
+	} else {
+		ctrl.Username = fakecontroller.Username
+		ctrl.Password = fakecontroller.Password
+	}
+
+	client, err := unifi.NewClient(ctrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func hasStatus(code int) func(error) bool {
+	return func(err error) bool {
+		errStatus := &unifi.ErrUnexpectedStatus{}
+		return errors.As(err, &errStatus) && errStatus.Status == code
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func TestControllerGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		unifiOS bool // only for UniFi OS, if set
+		apiKey  bool
+		setup   func(*fakecontroller.Server)
+		path    string
+
+		wantErr    func(error) bool // nil, if the request should succeed
+		wantLogins int              // including the login of the initial request
+	}{
+		{
+			name:       "session",
+			path:       devicesPath,
+			wantLogins: 1,
+		},
+		{
+			name:       "api key",
+			unifiOS:    true,
+			apiKey:     true,
+			path:       devicesPath,
+			wantLogins: 0,
+		},
+		{
+			name: "re-login after 401",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject(devicesPath, fakecontroller.Fault{Status: http.StatusUnauthorized, Count: 1})
+			},
+			path:       devicesPath,
+			wantLogins: 2,
+		},
+		{
+			name:       "expired session",
+			setup:      (*fakecontroller.Server).ExpireSessions,
+			path:       devicesPath,
+			wantLogins: 2,
+		},
+		{
+			name: "persistent 401",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject(devicesPath, fakecontroller.Fault{Status: http.StatusUnauthorized})
+			},
+			path:       devicesPath,
+			wantErr:    hasStatus(http.StatusUnauthorized),
+			wantLogins: 2, // only one retry
+		},
+		{
+			name: "failed login",
+			setup: func(s *fakecontroller.Server) {
+				s.ExpireSessions()
+				s.Inject("/api/login", fakecontroller.Fault{Status: http.StatusServiceUnavailable})
+			},
+			path:       devicesPath,
+			wantErr:    hasStatus(http.StatusServiceUnavailable),
+			wantLogins: 2,
+		},
+		{
+			name: "rc error",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject(devicesPath, fakecontroller.Fault{Error: "api.err.NoPermission"})
+			},
+			path: devicesPath,
+			wantErr: func(err error) bool {
+				return errors.Is(err, unifi.ErrRequestFailed("api.err.NoPermission"))
+			},
+			wantLogins: 1,
+		},
+		{
+			name:  "malformed JSON",
+			setup: func(s *fakecontroller.Server) { s.Inject(devicesPath, fakecontroller.Fault{Malformed: true}) },
+			path:  devicesPath,
+			wantErr: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "decoding")
+			},
+			wantLogins: 1,
+		},
+		{
+			name:       "slow response",
+			setup:      func(s *fakecontroller.Server) { s.Inject(devicesPath, fakecontroller.Fault{Delay: time.Second}) },
+			path:       devicesPath,
+			wantErr:    isTimeout,
+			wantLogins: 1,
+		},
+		{
+			name:       "unknown site",
+			path:       "/api/s/unknown/stat/device",
+			wantErr:    hasStatus(http.StatusNotFound),
+			wantLogins: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, unifiOS := range []bool{false, true} {
+			if tt.unifiOS && !unifiOS {
+				continue
+			}
+
+			name := tt.name + "/classic"
+			if unifiOS {
+				name = tt.name + "/unifi-os"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				srv := fakecontroller.New(unifiOS)
+				defer srv.Close()
+
+				client := newClient(t, srv, tt.apiKey, 200*time.Millisecond)
+				ctx := context.Background()
+
+				// establish a session
+				var sites []interface{}
+				if err := client.Get(ctx, "/api/self/sites", &sites); err != nil {
+					t.Fatalf("listing sites failed: %v", err)
+				}
+				if len(sites) != 2 {
+					t.Errorf("got %d sites, want 2", len(sites))
+				}
+
+				if tt.setup != nil {
+					tt.setup(srv)
+				}
+
+				var devices []map[string]interface{}
+				err := client.Get(ctx, tt.path, &devices)
+
+				switch {
+				case tt.wantErr == nil && err != nil:
+					t.Errorf("unexpected error: %v", err)
+				case tt.wantErr == nil && len(devices) != 4:
+					t.Errorf("got %d devices, want 4", len(devices))
+				case tt.wantErr != nil && !tt.wantErr(err):
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				if got := srv.Requests("/api/login"); got != tt.wantLogins {
+					t.Errorf("got %d logins, want %d", got, tt.wantLogins)
+				}
+			})
+		}
+	}
+}
+
+func TestControllerConcurrentRelogin(t *testing.T) {
+	for _, unifiOS := range []bool{false, true} {
+		srv := fakecontroller.New(unifiOS)
+		defer srv.Close()
+
+		client := newClient(t, srv, false, 0)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var devices []interface{}
+				if err := client.Get(context.Background(), devicesPath, &devices); err != nil {
+					t.Errorf("unifiOS=%t: %v", unifiOS, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got := srv.Requests("/api/login"); got != 1 {
+			t.Errorf("unifiOS=%t: got %d logins, want 1", unifiOS, got)
+		}
+	}
+}
+
+func TestControllerMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		unifiOS  bool
+		apiKey   bool
+		site     string
+		stations bool
+		events   bool
+		setup    func(*fakecontroller.Server)
+		deadline time.Duration
+
+		wantErr     func(error) bool
+		wantSite    unifi.Site
+		wantFetched unifi.Section
+	}{
+		{
+			name:        "classic",
+			site:        "default",
+			wantSite:    unifi.Site{Name: "default", Desc: "Default"},
+			wantFetched: unifi.SectionHealth | unifi.SectionSubsystems | unifi.SectionNetworks | unifi.SectionDevices | unifi.SectionWLANs,
+		},
+		{
+			name:        "unifi os by description",
+			unifiOS:     true,
+			site:        "Branch Office",
+			wantSite:    unifi.Site{Name: "x7k2m9qp", Desc: "Branch Office"},
+			wantFetched: unifi.SectionHealth | unifi.SectionSubsystems | unifi.SectionNetworks | unifi.SectionDevices | unifi.SectionWLANs,
+		},
+		{
+			name:        "api key with stations and events",
+			unifiOS:     true,
+			apiKey:      true,
+			site:        "default",
+			stations:    true,
+			events:      true,
+			wantSite:    unifi.Site{Name: "default", Desc: "Default"},
+			wantFetched: unifi.SectionHealth | unifi.SectionSubsystems | unifi.SectionNetworks | unifi.SectionDevices | unifi.SectionWLANs | unifi.SectionStations | unifi.SectionAlarms | unifi.SectionEvents,
+		},
+		{
+			name: "unknown site",
+			site: "unknown",
+			wantErr: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "not found")
+			},
+		},
+		{
+			name:     "deadline",
+			site:     "default",
+			setup:    func(s *fakecontroller.Server) { s.Inject(devicesPath, fakecontroller.Fault{Delay: time.Second}) },
+			deadline: 300 * time.Millisecond,
+			wantErr: func(err error) bool {
+				return errors.Is(err, context.DeadlineExceeded)
+			},
+			wantSite:    unifi.Site{Name: "default", Desc: "Default"},
+			wantFetched: unifi.SectionHealth,
+		},
+		{
+			name: "subsystems and network configuration failing",
+			site: "default",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/stat/health", fakecontroller.Fault{Status: http.StatusInternalServerError})
+				s.Inject("/api/s/default/rest/networkconf", fakecontroller.Fault{Error: "api.err.NoPermission"})
+			},
+			wantSite:    unifi.Site{Name: "default", Desc: "Default"},
+			wantFetched: unifi.SectionHealth | unifi.SectionDevices | unifi.SectionWLANs,
+		},
+		{
+			name: "malformed health",
+			site: "default",
+			setup: func(s *fakecontroller.Server) {
+				s.Inject("/api/s/default/stat/widget/health", fakecontroller.Fault{Malformed: true})
+			},
+			wantErr: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "decoding")
+			},
+			wantSite: unifi.Site{Name: "default", Desc: "Default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakecontroller.New(tt.unifiOS)
+			defer srv.Close()
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			ctrl := &unifi.Controller{
+				URL:            srv.URL,
+				StationMetrics: tt.stations,
+				EventMetrics:   tt.events,
+			}
+			if tt.apiKey {
+				ctrl.APIKey = fakecontroller.APIKey
+			} else {
+				ctrl.Username = fakecontroller.Username
+				ctrl.Password = fakecontroller.Password
+			}
+			client, err := unifi.NewClient(ctrl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			m, err := client.Metrics(ctx, tt.site)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != nil && !tt.wantErr(err):
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantSite == (unifi.Site{}) {
+				if m != nil {
+					t.Errorf("got metrics %+v, want nil", m)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatal("got no metrics")
+			}
+
+			if m.Site != tt.wantSite {
+				t.Errorf("got site %+v, want %+v", m.Site, tt.wantSite)
+			}
+			if m.ControllerVersion != "8.0.28" {
+				t.Errorf("got controller version %q, want 8.0.28", m.ControllerVersion)
+			}
+			if m.Fetched != tt.wantFetched {
+				t.Errorf("got fetched sections %b, want %b", m.Fetched, tt.wantFetched)
+			}
+
+			if m.Has(unifi.SectionHealth) {
+				if m.AvgWifiUtilization24 != 31 || m.AvgWifiUtilization50 != 12.5 {
+					t.Errorf("got wifi utilization %v/%v, want 31/12.5", m.AvgWifiUtilization24, m.AvgWifiUtilization50)
+				}
+				if m.ClientsPoorScore != 1 || m.ClientsFairScore != 2 || m.ClientsGoodScore != 11 {
+					t.Errorf("got client scores %d/%d/%d, want 1/2/11", m.ClientsPoorScore, m.ClientsFairScore, m.ClientsGoodScore)
+				}
+			}
+			if m.Has(unifi.SectionSubsystems) && len(m.Subsystems) != 5 {
+				t.Errorf("got %d subsystems, want 5", len(m.Subsystems))
+			}
+			if m.Has(unifi.SectionNetworks) && len(m.SiteToSiteTunnels) != 2 {
+				t.Errorf("got %d site-to-site tunnels, want 2", len(m.SiteToSiteTunnels))
+			}
+			if got := len(m.Devices); m.Has(unifi.SectionDevices) && got != 3 || !m.Has(unifi.SectionDevices) && got != 0 {
+				t.Errorf("got %d devices", got) // the unadopted device is skipped
+			}
+			if m.Has(unifi.SectionWLANs) && len(m.WLANs) != 2 {
+				t.Errorf("got %d WLANs, want 2", len(m.WLANs))
+			}
+			if m.Has(unifi.SectionStations) && len(m.Stations) != 2 {
+				t.Errorf("got %d stations, want 2", len(m.Stations))
+			}
+			if m.Has(unifi.SectionAlarms) && len(m.Alarms) != 1 {
+				t.Errorf("got %d active alarms, want 1", len(m.Alarms))
+			}
+		})
+	}
+}
@@ -17,13 +17,43 @@ func (err *ErrInvalidEndpoint) Unwrap() error {
 	return err.err
 }
 
+type ErrInvalidTLSConfig struct {
+	err error
+}
+
+func (err *ErrInvalidTLSConfig) Error() string {
+	return fmt.Sprintf("invalid TLS config: %v", err.err)
+}
+
+func (err *ErrInvalidTLSConfig) Unwrap() error {
+	return err.err
+}
+
 type ErrRequestFailed string
 
 func (err ErrRequestFailed) Error() string {
 	return fmt.Sprintf("request failed: %s", string(err))
 }
 
-var ErrMissingCredentials = errors.New("missing username/password")
+type ErrInvalidType struct {
+	typ string
+}
+
+func (err *ErrInvalidType) Error() string {
+	return fmt.Sprintf("invalid controller type '%s'", err.typ)
+}
+
+type ErrInvalidReport struct {
+	param, value string
+}
+
+func (err *ErrInvalidReport) Error() string {
+	return fmt.Sprintf("invalid report %s '%s'", err.param, err.value)
+}
+
+var ErrMissingCredentials = errors.New("missing username/password or API key")
+
+var ErrMissingReplayDir = errors.New("missing replay-dir for replay controller")
 
 type genericError struct{ msg string }
 
@@ -0,0 +1,158 @@
+package unifi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// delays between reconnection attempts (variable for testing).
+var (
+	minEventStreamBackoff = time.Second
+	maxEventStreamBackoff = 5 * time.Minute
+)
+
+// SubscribeEvents connects to the event stream of a site and calls fn
+// for each received event. Lost connections are re-established with
+// an exponential backoff. SubscribeEvents returns when ctx is canceled.
+func (c *Controller) SubscribeEvents(ctx context.Context, siteDesc string, fn func(Event)) {
+	if c.Type == TypeReplay {
+		log.Printf("event stream for site %q of %s: not available for recordings", siteDesc, c.TargetName())
+		return
+	}
+
+	backoff := minEventStreamBackoff
+
+	for {
+		connected, err := c.streamEvents(ctx, siteDesc, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = minEventStreamBackoff
+		}
+
+		log.Printf("event stream for site %q of %s interrupted: %v (reconnecting in %v)", siteDesc, c.TargetName(), err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxEventStreamBackoff {
+			backoff = maxEventStreamBackoff
+		}
+	}
+}
+
+// streamEvents reads events until the connection fails. The returned
+// flag indicates, whether a connection could be established at all.
+func (c *Controller) streamEvents(ctx context.Context, siteDesc string, fn func(Event)) (bool, error) {
+	site, err := c.fetchSite(ctx, siteDesc)
+	if err != nil {
+		return false, err
+	}
+
+	session := c.sessionID()
+	conn, err := c.dialEvents(ctx, site.Name)
+
+	// the session might have expired
+	errStatus := &ErrUnexpectedStatus{}
+	if c.APIKey == "" && errors.As(err, &errStatus) && (errStatus.Status == http.StatusUnauthorized || errStatus.Status == http.StatusForbidden) {
+		if err = c.relogin(ctx, session); err == nil {
+			conn, err = c.dialEvents(ctx, site.Name)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// unblock ReadMessage on cancellation
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, fmt.Errorf("reading event stream failed: %w", err)
+		}
+
+		var msg eventStreamMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			vlogf("decoding event stream message failed: %v", err)
+			continue
+		}
+		if msg.Meta.Message != "events" {
+			continue
+		}
+
+		for _, raw := range msg.Data {
+			var ev eventResponse
+			if err := json.Unmarshal(raw, &ev); err != nil {
+				vlogf("decoding event failed: %v", err)
+				continue
+			}
+			fn(ev.event())
+		}
+	}
+}
+
+func (c *Controller) dialEvents(ctx context.Context, siteName string) (*websocket.Conn, error) {
+	if err := c.detectType(ctx); err != nil {
+		return nil, err
+	}
+
+	path := strings.Replace(siteEventStreamPath, "{siteName}", siteName, 1)
+	if c.isUnifiOS() {
+		path = unifiOSPathPrefix + path
+	}
+
+	scheme := "ws"
+	if c.endpoint.Scheme == "https" {
+		scheme = "wss"
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, c.endpoint.Host, path)
+	vlogf("GET %s", url)
+
+	transport := c.client.Transport.(*http.Transport)
+	dialer := websocket.Dialer{
+		Proxy:            transport.Proxy,
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		HandshakeTimeout: c.Timeout,
+		Jar:              c.client.Jar,
+	}
+
+	header := http.Header{}
+	if c.APIKey != "" {
+		header.Set("X-API-KEY", c.APIKey)
+	}
+	c.sessionMu.RLock()
+	if c.csrfToken != "" {
+		header.Set("X-CSRF-Token", c.csrfToken)
+	}
+	c.sessionMu.RUnlock()
+
+	conn, res, err := dialer.DialContext(ctx, url, header)
+	if err != nil {
+		if res != nil && res.StatusCode != http.StatusSwitchingProtocols {
+			return nil, &ErrUnexpectedStatus{
+				Method: http.MethodGet,
+				URL:    url,
+				Status: res.StatusCode,
+			}
+		}
+		return nil, fmt.Errorf("connecting to event stream failed: %w", err)
+	}
+
+	return conn, nil
+}
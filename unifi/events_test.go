@@ -0,0 +1,222 @@
+package unifi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventServer is a minimal controller serving the site list, the login
+// and the event stream of the "default" site.
+type eventServer struct {
+	*httptest.Server
+
+	requireLogin bool // reject the stream handshake without session
+	failDials    int  // number of handshakes to fail with 500
+	closeAfter   bool // close each connection after the messages are sent
+	messages     []string
+
+	mu     sync.Mutex
+	logins int
+	dials  []time.Time // all handshake attempts
+}
+
+func newEventServer(t *testing.T, es *eventServer) *Controller {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/self/sites", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"name":"default","desc":"Default"}]}`)
+	})
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, _ *http.Request) {
+		es.mu.Lock()
+		es.logins++
+		es.mu.Unlock()
+
+		http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "session", Path: "/"})
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[]}`)
+	})
+	mux.HandleFunc("/wss/s/default/events", es.serveEvents)
+
+	es.Server = httptest.NewServer(mux)
+	t.Cleanup(es.Close)
+
+	c, err := NewClient(&Controller{
+		URL:      es.URL,
+		Type:     TypeClassic,
+		Username: "admin",
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Controller)
+}
+
+func (es *eventServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	es.mu.Lock()
+	es.dials = append(es.dials, time.Now())
+	fail := len(es.dials) <= es.failDials
+	es.mu.Unlock()
+
+	if fail {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+	if _, err := r.Cookie(sessionCookieName); es.requireLogin && err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, msg := range es.messages {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return
+		}
+	}
+	if es.closeAfter {
+		return
+	}
+
+	// wait for the client to disconnect
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (es *eventServer) stats() (logins int, dials []time.Time) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.logins, append([]time.Time(nil), es.dials...)
+}
+
+const (
+	lostContactMessage = `{"meta":{"rc":"ok","message":"events"},"data":[` +
+		`{"_id":"e1","key":"EVT_AP_Lost_Contact","subsystem":"wlan","msg":"AP lost contact","time":1700000000000},` +
+		`{"_id":"e2","key":"EVT_AP_Connected","subsystem":"wlan","msg":"AP connected","time":1700000060000}]}`
+	syncMessage = `{"meta":{"rc":"ok","message":"sta:sync"},"data":[{"mac":"00:11:22:33:44:55"}]}`
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	minBackoff, maxBackoff := minEventStreamBackoff, maxEventStreamBackoff
+	minEventStreamBackoff, maxEventStreamBackoff = 20*time.Millisecond, 100*time.Millisecond
+	t.Cleanup(func() {
+		minEventStreamBackoff, maxEventStreamBackoff = minBackoff, maxBackoff
+	})
+
+	tests := []struct {
+		name   string
+		server *eventServer
+		events int // to wait for
+
+		wantLogins int
+		minDials   int
+	}{
+		{
+			name:     "delivery",
+			server:   &eventServer{messages: []string{syncMessage, "not json", lostContactMessage}},
+			events:   2,
+			minDials: 1,
+		},
+		{
+			name:     "reconnect after failed handshakes",
+			server:   &eventServer{failDials: 2, messages: []string{lostContactMessage}},
+			events:   2,
+			minDials: 3,
+		},
+		{
+			name:     "reconnect after closed connection",
+			server:   &eventServer{closeAfter: true, messages: []string{lostContactMessage}},
+			events:   4,
+			minDials: 2,
+		},
+		{
+			name:       "login after 401",
+			server:     &eventServer{requireLogin: true, messages: []string{lostContactMessage}},
+			events:     2,
+			wantLogins: 1,
+			minDials:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tt.server
+			c := newEventServer(t, srv)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			received := make(chan Event, 16)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.SubscribeEvents(ctx, "Default", func(ev Event) {
+					select {
+					case received <- ev:
+					case <-ctx.Done():
+					}
+				})
+			}()
+
+			var events []Event
+			timeout := time.After(5 * time.Second)
+			for len(events) < tt.events {
+				select {
+				case ev := <-received:
+					events = append(events, ev)
+				case <-timeout:
+					t.Fatalf("received %d events, want %d", len(events), tt.events)
+				}
+			}
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("SubscribeEvents did not return after cancellation")
+			}
+
+			want := Event{
+				ID:        "e1",
+				Key:       "EVT_AP_Lost_Contact",
+				Subsystem: "wlan",
+				Message:   "AP lost contact",
+				Time:      time.UnixMilli(1700000000000),
+			}
+			if got := events[0]; got != want {
+				t.Errorf("got event %+v, want %+v", got, want)
+			}
+			if got := events[1].Key; got != "EVT_AP_Connected" {
+				t.Errorf("got second event key %q, want EVT_AP_Connected", got)
+			}
+
+			logins, dials := srv.stats()
+			if logins != tt.wantLogins {
+				t.Errorf("got %d logins, want %d", logins, tt.wantLogins)
+			}
+			if len(dials) < tt.minDials {
+				t.Fatalf("got %d handshakes, want at least %d", len(dials), tt.minDials)
+			}
+
+			// the delay doubles after each failed handshake
+			for i := 1; i <= srv.failDials; i++ {
+				want := minEventStreamBackoff << (i - 1)
+				if got := dials[i].Sub(dials[i-1]); got < want {
+					t.Errorf("handshake %d after %v, want at least %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+// Package fakecontroller provides a fake UniFi Network controller for
+// offline testing. It serves recorded API responses (see fixtures/)
+// and can inject faults, like expired sessions or malformed replies.
+//
+//	srv := fakecontroller.New(false)
+//	defer srv.Close()
+//
+//	client, err := unifi.NewClient(&unifi.Controller{
+//		URL:      srv.URL,
+//		Username: fakecontroller.Username,
+//		Password: fakecontroller.Password,
+//	})
+package fakecontroller
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials accepted by the fake controller.
+const (
+	Username = "admin"
+	Password = "password"
+	APIKey   = "fake-api-key"
+)
+
+const (
+	sessionCookieName        = "unifises"
+	unifiOSSessionCookieName = "TOKEN"
+	unifiOSPathPrefix        = "/proxy/network"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// maps site API paths (below /api/s/{siteName}/) to fixture files.
+var sitePaths = map[string]string{
+	"stat/widget/health": "health.json",
+	"stat/health":        "subsystems.json",
+	"stat/device":        "devices.json",
+	"stat/sta":           "stations.json",
+	"stat/alarm":         "alarms.json",
+	"stat/event":         "events.json",
+	"rest/wlanconf":      "wlanconf.json",
+	"rest/networkconf":   "networkconf.json",
+}
+
+// Fault describes an error to inject for a path.
+type Fault struct {
+	Status    int           // reply with this HTTP status code, if not zero
+	Error     string        // reply with rc "error" and this message, if not empty
+	Delay     time.Duration // delay the reply
+	Malformed bool          // reply with invalid JSON
+	Count     int           // number of affected requests, 0 for all
+}
+
+// Server is a fake UniFi controller. It behaves like a UniFi OS console
+// (with login at /api/auth/login and API paths below /proxy/network),
+// if UnifiOS is set, and like a classic controller otherwise.
+type Server struct {
+	*httptest.Server
+	UnifiOS bool
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	faults   map[string]*Fault
+	requests map[string]int
+}
+
+// New starts a fake controller. Close it when done.
+func New(unifiOS bool) *Server {
+	s := newServer(unifiOS)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLS is like New, but serves HTTPS with a self-signed certificate
+// (see httptest.Server.Certificate).
+func NewTLS(unifiOS bool) *Server {
+	s := newServer(unifiOS)
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func newServer(unifiOS bool) *Server {
+	return &Server{
+		UnifiOS:  unifiOS,
+		sessions: make(map[string]bool),
+		faults:   make(map[string]*Fault),
+		requests: make(map[string]int),
+	}
+}
+
+// Inject registers a fault for a path, as seen by a classic controller
+// (e.g. "/api/s/default/stat/device", or "/api/login" for both login
+// endpoints). It replaces previous faults for that path.
+func (s *Server) Inject(path string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[path] = &f
+}
+
+// Reset removes all injected faults.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string]*Fault)
+}
+
+// ExpireSessions invalidates all sessions, so that clients need to
+// log in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+// Requests returns the number of requests for a path (see Inject).
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+
+	if p == "/" {
+		// used for detecting UniFi OS
+		if s.UnifiOS {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, "<!doctype html><title>UniFi OS</title>")
+		} else {
+			http.Redirect(w, r, "/manage", http.StatusFound)
+		}
+		return
+	}
+
+	if s.UnifiOS {
+		if p == "/api/auth/login" {
+			s.handleLogin(w, r)
+			return
+		}
+		if !strings.HasPrefix(p, unifiOSPathPrefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		p = strings.TrimPrefix(p, unifiOSPathPrefix)
+	} else if p == "/api/login" {
+		s.handleLogin(w, r)
+		return
+	}
+
+	if !s.applyFault(w, r, p) {
+		return
+	}
+
+	if p == "/status" {
+		// available without session
+		s.writeFixture(w, "status.json", false)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeMeta(w, "error", "api.err.LoginRequired")
+		return
+	}
+
+	if p == "/api/self/sites" {
+		s.writeFixture(w, "sites.json", true)
+		return
+	}
+
+	site, rest, ok := splitSitePath(p)
+	if !ok || !s.siteExists(site) {
+		w.WriteHeader(http.StatusNotFound)
+		writeMeta(w, "error", "api.err.NoSiteContext")
+		return
+	}
+
+	if file, ok := sitePaths[rest]; ok {
+		s.writeFixture(w, file, true)
+		return
+	}
+	if strings.HasPrefix(rest, "stat/report/") {
+		// same rows for all intervals and types
+		s.writeFixture(w, "report.json", true)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.applyFault(w, r, "/api/login") {
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeMeta(w, "error", "api.err.Invalid")
+		return
+	}
+	if req.Username != Username || req.Password != Password {
+		w.WriteHeader(http.StatusBadRequest)
+		writeMeta(w, "error", "api.err.Invalid")
+		return
+	}
+
+	token := randomToken()
+	s.mu.Lock()
+	s.sessions[token] = true
+	s.mu.Unlock()
+
+	if s.UnifiOS {
+		http.SetCookie(w, &http.Cookie{Name: unifiOSSessionCookieName, Value: token, Path: "/", HttpOnly: true})
+		w.Header().Set("X-CSRF-Token", randomToken())
+		// UniFi OS responds with the user object
+		fmt.Fprintf(w, `{"username":%q,"isOwner":true}`, Username)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: token, Path: "/", HttpOnly: true})
+	writeMeta(w, "ok", "")
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.UnifiOS && r.Header.Get("X-API-KEY") == APIKey {
+		return true
+	}
+
+	name := sessionCookieName
+	if s.UnifiOS {
+		name = unifiOSSessionCookieName
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[cookie.Value]
+}
+
+// applyFault counts the request and applies an injected fault. It
+// returns false, if the reply has been written.
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request, path string) bool {
+	s.mu.Lock()
+	s.requests[path]++
+	f := s.faults[path]
+	var fault Fault
+	if f != nil {
+		fault = *f
+		if f.Count > 0 {
+			if f.Count--; f.Count == 0 {
+				delete(s.faults, path)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if f == nil {
+		return true
+	}
+
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	switch {
+	case fault.Status != 0:
+		w.WriteHeader(fault.Status)
+		writeMeta(w, "error", http.StatusText(fault.Status))
+	case fault.Error != "":
+		writeMeta(w, "error", fault.Error)
+	case fault.Malformed:
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"mac":`)
+	default:
+		return true // only delayed
+	}
+	return false
+}
+
+func (s *Server) siteExists(name string) bool {
+	var sites []struct {
+		Name string `json:"name"`
+	}
+	data, err := fixtures.ReadFile("fixtures/sites.json")
+	if err != nil || json.Unmarshal(data, &sites) != nil {
+		return false
+	}
+	for _, site := range sites {
+		if site.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFixture writes a fixture, optionally wrapped into a meta response.
+func (s *Server) writeFixture(w http.ResponseWriter, file string, wrap bool) {
+	data, err := fixtures.ReadFile(path.Join("fixtures", file))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if wrap {
+		fmt.Fprintf(w, `{"meta":{"rc":"ok"},"data":%s}`, data)
+	} else {
+		w.Write(data) //nolint:errcheck
+	}
+}
+
+// splitSitePath splits "/api/s/{siteName}/{rest}".
+func splitSitePath(p string) (site, rest string, ok bool) {
+	const prefix = "/api/s/"
+	if !strings.HasPrefix(p, prefix) {
+		return "", "", false
+	}
+	site, rest, ok = strings.Cut(p[len(prefix):], "/")
+	return site, rest, ok && site != ""
+}
+
+func writeMeta(w http.ResponseWriter, rc, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"meta":{"rc":%q,"msg":%q},"data":[]}`, rc, msg)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b) //nolint:errcheck
+	return hex.EncodeToString(b)
+}
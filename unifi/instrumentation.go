@@ -0,0 +1,71 @@
+package unifi
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "api_request_duration_seconds",
+		Help:      "duration of controller API requests",
+	}, []string{"controller", "path"})
+
+	apiResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "api_responses_total",
+		Help:      "number of controller API responses by HTTP status code",
+	}, []string{"controller", "path", "code"})
+
+	apiDecodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "api_decode_failures_total",
+		Help:      "number of controller API responses which could not be decoded",
+	}, []string{"controller", "path"})
+
+	apiRequestFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "api_request_failures_total",
+		Help:      "number of controller API responses with an error message",
+	}, []string{"controller", "path", "message"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "unifi_sdn",
+		Subsystem: "exporter",
+		Name:      "logins_total",
+		Help:      "number of logins into the controller",
+	}, []string{"controller", "result"})
+)
+
+// MustRegisterInstrumentation registers the metrics describing the
+// controller API usage.
+func MustRegisterInstrumentation(reg prometheus.Registerer) {
+	reg.MustRegister(apiDuration, apiResponses, apiDecodeFailures, apiRequestFailures, logins)
+}
+
+// pathTemplate removes the site name, the UniFi OS prefix and the query
+// string from API paths, to keep the cardinality of the instrumentation
+// metrics low.
+func pathTemplate(path string) string {
+	path = strings.TrimPrefix(path, unifiOSPathPrefix)
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	const prefix = "/api/s/"
+	if !strings.HasPrefix(path, prefix) {
+		return path
+	}
+
+	rest := path[len(prefix):]
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return prefix + "{siteName}" + rest[i:]
+	}
+	return prefix + "{siteName}"
+}
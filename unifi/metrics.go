@@ -2,8 +2,29 @@ package unifi
 
 import "time"
 
+// Section identifies a part of the Metrics, which is fetched with a
+// separate API request.
+type Section uint
+
+// Metrics sections, in the order they're fetched.
+const (
+	SectionHealth     Section = 1 << iota // wifi utilization and client scores
+	SectionDevices                        // Devices and SSIDs
+	SectionSubsystems                     // Subsystems, WAN, WWW and VPN
+	SectionNetworks                       // SiteToSiteTunnels
+	SectionWLANs                          // WLANs
+	SectionStations                       // Stations
+	SectionAlarms                         // Alarms
+	SectionEvents                         // Events
+)
+
 type Metrics struct {
 	ControllerVersion string
+	Site              Site
+
+	// Fetched marks the sections which have been fetched. Metrics
+	// returned with an error may be incomplete.
+	Fetched Section
 
 	AvgWifiUtilization24 float64
 	AvgWifiUtilization50 float64
@@ -13,16 +34,95 @@ type Metrics struct {
 	ClientsFairScore int
 	ClientsGoodScore int
 
-	Devices []DeviceMetrics
+	Subsystems []SubsystemMetrics
+	WAN        *WANHealth // from subsystem "wan"
+	WWW        *WWWHealth // from subsystem "www"
+	VPN        *VPNHealth // from subsystem "vpn"
+
+	SiteToSiteTunnels []VPNTunnelMetrics // from the network configuration
+
+	WLANs []WLANMetrics // from the WLAN configuration
+	SSIDs []SSIDMetrics // summed up from the devices' virtual APs
+
+	Devices  []DeviceMetrics
+	Stations []StationMetrics // only if Controller.StationMetrics is set
+
+	// only if Controller.EventMetrics is set
+	Alarms    []AlarmMetrics
+	Events    map[string]uint64 // number of events by key since the first fetch
+	EventGaps uint64            // number of fetches, which may have missed events
+}
+
+// Has reports whether the section has been fetched.
+func (m *Metrics) Has(s Section) bool {
+	return m.Fetched&s != 0
+}
+
+// SubsystemMetrics describes the health of a site subsystem. Counters
+// are nil, if the subsystem doesn't provide them.
+type SubsystemMetrics struct {
+	Name   string
+	Status string
+
+	Adopted      *int
+	Disconnected *int
+	Pending      *int
+	Disabled     *int
+
+	Users  *int
+	Guests *int
+	IoT    *int
+}
+
+type WANHealth struct {
+	IP              string
+	ISPName         string
+	ISPOrganization string
+	GatewayMAC      string
+	GatewayName     string
+	GatewayVersion  string
+	GatewayUptime   *time.Duration
+}
+
+type WWWHealth struct {
+	Latency          *int // in ms
+	Uptime           *time.Duration
+	Drops            *int
+	SpeedtestLastRun time.Time
+	SpeedtestPing    *int     // in ms
+	SpeedtestDown    *float64 // in MBit/s
+	SpeedtestUp      *float64 // in MBit/s
+}
+
+type VPNHealth struct {
+	RemoteUserEnabled  bool
+	RemoteUserActive   *int
+	RemoteUserInactive *int
+	SiteToSiteEnabled  bool
+}
+
+// VPNTunnelMetrics describes a configured site-to-site VPN tunnel.
+// The controller doesn't report the state of the tunnels.
+type VPNTunnelMetrics struct {
+	Name    string
+	Type    string // e.g. "ipsec-vpn", "openvpn-vpn"
+	Enabled bool
 }
 
 type DeviceMetrics struct {
 	MAC        string
+	Name       string
+	Type       string // "uap", "usw", "ugw", "udm", "uxg", ...
+	IP         string
+	Serial     string
+	SiteID     string
 	Firmware   string
 	Model      string
 	ModelHuman string
 	Adopted    bool
 	LTS, EOL   bool
+	Upgradable bool
+	UpgradeTo  string // firmware version, if Upgradable
 
 	Status      int
 	StatusHuman string
@@ -35,6 +135,169 @@ type DeviceMetrics struct {
 	PowerMax    *int
 	PowerUsed   *float32
 	Temperature *int
+	CPU         *float64 // in percent
+	Memory      *float64 // in percent
+
+	// only for gateways
+	WANs      []WANMetrics
+	Speedtest *SpeedtestMetrics
+
+	Radios     map[string]int
+	RadioStats []RadioMetrics
+	VAPs       []VAPMetrics
+	Ports      []PortMetrics
+}
+
+type WANMetrics struct {
+	Name      string // "wan1", "wan2"
+	Interface string
+	IP        string
+	Up        bool
+	Enabled   bool
+	Active    bool // whether this is the current uplink
+	Speed     int  // in MBit/s
+
+	RxBytes int64
+	TxBytes int64
+
+	Latency      *float64 // in ms
+	Availability *float64 // in percent
+}
+
+type SpeedtestMetrics struct {
+	LastRun  time.Time
+	Latency  float64 // in ms
+	Download float64 // in MBit/s
+	Upload   float64 // in MBit/s
+}
+
+// WLANMetrics describes a configured WLAN.
+type WLANMetrics struct {
+	ID       string // distinguishes WLANs with the same SSID
+	ESSID    string
+	Enabled  bool
+	Security string // e.g. "wpapsk" or "wpapsk/wpa3"
+	VLAN     int    // 0, if no VLAN is configured
+	Bands    string // e.g. "2g,5g"
+	Hidden   bool
+	Guest    bool
+}
+
+// SSIDMetrics aggregates the virtual APs of a site by SSID. The byte
+// counts are not monotonic, as they decrease when an AP restarts or
+// stops serving the SSID.
+type SSIDMetrics struct {
+	ESSID   string
+	Clients int
+	RxBytes int64
+	TxBytes int64
+}
+
+// VAPMetrics describes a virtual AP, i.e. a SSID on an AP's radio.
+type VAPMetrics struct {
+	ESSID        string
+	BSSID        string
+	Radio        string // refers to RadioMetrics.Name
+	Band         string
+	Clients      int
+	RxBytes      int64
+	TxBytes      int64
+	Satisfaction *int
+}
+
+type RadioMetrics struct {
+	Name         string
+	Band         string
+	Channel      int
+	ChannelWidth int // in MHz
+	TxPower      int // in dBm
+	Clients      int
+	Satisfaction *int
+
+	// channel utilization in percent
+	CUTotal      int
+	CUSelfRx     int
+	CUSelfTx     int
+	Interference int // CUTotal minus own traffic
+
+	TxPackets int64
+	TxRetries int64
+	RxPackets int64 // summed up from virtual APs
+}
+
+type PortMetrics struct {
+	Index      int
+	Name       string
+	Up         bool
+	Enabled    bool
+	Speed      int // in MBit/s
+	FullDuplex bool
+	STPState   string
+
+	RxBytes   int64
+	TxBytes   int64
+	RxPackets int64
+	TxPackets int64
+	RxErrors  int64
+	TxErrors  int64
+	RxDropped int64
+	TxDropped int64
+
+	PoE *PoEMetrics // only for PoE capable ports
+	SFP *SFPMetrics // only if a module is present
+}
+
+type PoEMetrics struct {
+	Mode    string
+	Class   string
+	Power   float64 // in W
+	Voltage float64 // in V
+	Current float64 // in mA
+}
+
+type SFPMetrics struct {
+	Temperature float64 // in Celsius
+	RxPower     float64
+}
+
+type StationMetrics struct {
+	MAC      string
+	Hostname string
+	Name     string
+	OUI      string
+
+	Wired bool
+	APMAC string // empty for wired stations
+	SWMAC string
+	ESSID string
+	Band  string
+	VLAN  int
+
+	Channel      int
+	RSSI         int
+	Signal       int
+	Noise        int
+	TxRate       int // in kBit/s
+	RxRate       int // in kBit/s
+	Satisfaction *int
+
+	TxBytes int64
+	RxBytes int64
+	Uptime  time.Duration
+}
+
+// AlarmMetrics counts the active (unarchived) alarms by key and device.
+type AlarmMetrics struct {
+	Key       string // e.g. "EVT_GW_WANTransition"
+	DeviceMAC string // empty, if the alarm doesn't refer to a device
+	Count     int
+}
 
-	Radios map[string]int
+// Event is a controller event, like a lost AP or a roaming client.
+type Event struct {
+	ID        string
+	Key       string // e.g. "EVT_AP_Lost_Contact"
+	Subsystem string // e.g. "wlan"
+	Message   string
+	Time      time.Time
 }
@@ -0,0 +1,91 @@
+package unifi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RecordDir, if set, is the directory where all raw API responses are
+// stored (in a sub directory per target). It can be used as ReplayDir
+// of a TypeReplay controller later on.
+var RecordDir string
+
+// RecordAnonymize replaces MAC addresses and secrets in recorded
+// responses.
+var RecordAnonymize bool
+
+var (
+	// characters not allowed in recording file names
+	invalidFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+	macPattern = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(?::[0-9a-f]{2}){5}\b`)
+
+	// string values of keys like "x_passphrase" or "x_ssh_password"
+	secretPattern = regexp.MustCompile(`("(?:x_[^"]*|[^"]*(?:password|passphrase|secret|token)[^"]*)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// recordingName maps an API path to a file name, e.g.
+// "/proxy/network/api/s/default/stat/device" to "api_s_default_stat_device.json".
+func recordingName(path string) string {
+	path = strings.TrimPrefix(path, unifiOSPathPrefix)
+	path = strings.Trim(path, "/")
+	return invalidFileChars.ReplaceAllString(path, "_") + ".json"
+}
+
+// record stores a raw API response. Failures are only logged.
+func (c *Controller) record(path string, data []byte) {
+	dir := filepath.Join(RecordDir, invalidFileChars.ReplaceAllString(c.TargetName(), "_"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		log.Printf("recording response failed: %v", err)
+		return
+	}
+
+	if RecordAnonymize {
+		data = anonymize(data)
+	}
+
+	file := filepath.Join(dir, recordingName(path))
+	if err := os.WriteFile(file, data, 0o640); err != nil { //nolint:gosec
+		log.Printf("recording response failed: %v", err)
+	}
+}
+
+// replay returns a recorded API response.
+func (c *Controller) replay(method, path string) ([]byte, error) {
+	file := filepath.Join(c.ReplayDir, recordingName(path))
+	vlogf("%s %s (replay from %s)", method, path, file)
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, &ErrUnexpectedStatus{
+			Method: method,
+			URL:    file,
+			Status: http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recording failed: %w", err)
+	}
+	return data, nil
+}
+
+// anonymize replaces secrets with "redacted", and MAC addresses with
+// pseudonyms. The same MAC address always gets the same pseudonym, so
+// that references between devices and clients are kept.
+func anonymize(data []byte) []byte {
+	data = secretPattern.ReplaceAll(data, []byte(`${1}"redacted"`))
+
+	return macPattern.ReplaceAllFunc(data, func(mac []byte) []byte {
+		sum := sha256.Sum256(bytes.ToLower(mac))
+		sum[0] = sum[0]&0xfc | 0x02 // locally administered, unicast
+		return []byte(net.HardwareAddr(sum[:6]).String())
+	})
+}
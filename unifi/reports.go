@@ -0,0 +1,93 @@
+package unifi
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReportIntervals lists the known report intervals. The retention
+// of each interval depends on the controller settings.
+var ReportIntervals = []string{"5minutes", "hourly", "daily", "monthly"}
+
+// ReportTypes lists the known report types.
+var ReportTypes = []string{"site", "ap", "gw", "user"}
+
+// ReportEntry is a single row of a historical report.
+type ReportEntry struct {
+	Time   time.Time
+	Object string             // MAC address, or site ID for "site" reports
+	Values map[string]float64 // by attribute, missing attributes are omitted
+}
+
+// Report fetches a pre-aggregated report from stat/report, e.g. the
+// "hourly" "ap" report with the attributes "bytes" and "num_sta".
+func (c *Controller) Report(ctx context.Context, siteDesc, interval, typ string, attrs []string, start, end time.Time) ([]ReportEntry, error) {
+	if !slices.Contains(ReportIntervals, interval) {
+		return nil, &ErrInvalidReport{"interval", interval}
+	}
+	if !slices.Contains(ReportTypes, typ) {
+		return nil, &ErrInvalidReport{"type", typ}
+	}
+
+	site, err := c.fetchSite(ctx, siteDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	req := reportRequest{
+		Attrs: append([]string{"time"}, attrs...),
+		Start: start.UnixMilli(),
+		End:   end.UnixMilli(),
+	}
+
+	path := strings.NewReplacer(
+		"{siteName}", site.Name,
+		"{interval}", interval,
+		"{type}", typ,
+	).Replace(siteReportPath)
+
+	vlogf("fetching %s %s report", interval, typ)
+	rows := []map[string]json.RawMessage{}
+	if err := c.Post(ctx, path, &req, &rows); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ReportEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := ReportEntry{
+			Values: make(map[string]float64, len(attrs)),
+		}
+
+		var ms quotedFloat
+		if err := json.Unmarshal(row["time"], &ms); err != nil || ms == 0 {
+			continue // unusable without timestamp
+		}
+		entry.Time = time.UnixMilli(int64(ms))
+
+		for _, key := range []string{"oid", typ, "mac"} {
+			if raw, ok := row[key]; ok && json.Unmarshal(raw, &entry.Object) == nil && entry.Object != "" {
+				break
+			}
+		}
+
+		for _, attr := range attrs {
+			raw, ok := row[attr]
+			if !ok {
+				continue
+			}
+			var val quotedFloat
+			if err := json.Unmarshal(raw, &val); err != nil {
+				vlogf("decoding report attribute %q failed: %v", attr, err)
+				continue
+			}
+			entry.Values[attr] = float64(val)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
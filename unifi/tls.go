@@ -0,0 +1,69 @@
+package unifi
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsConfig builds the TLS configuration for connections to the controller.
+func (c *Controller) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+		ServerName:         c.ServerName,
+	}
+
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file failed: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate failed: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.Fingerprints) > 0 {
+		// the chain isn't verified, so these would be ignored silently
+		if c.CAFile != "" || c.ServerName != "" {
+			return nil, errors.New("fingerprints cannot be combined with ca-file or server-name")
+		}
+
+		pins := make(map[[sha256.Size]byte]bool, len(c.Fingerprints))
+		for _, fp := range c.Fingerprints {
+			b, err := hex.DecodeString(strings.ReplaceAll(fp, ":", ""))
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", fp)
+			}
+			pins[[sha256.Size]byte(b)] = true
+		}
+
+		// A matching server certificate replaces the chain verification,
+		// which would fail for self-signed certificates anyway.
+		conf.InsecureSkipVerify = true
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) > 0 && pins[sha256.Sum256(rawCerts[0])] {
+				return nil
+			}
+			return errors.New("server certificate does not match any pinned fingerprint")
+		}
+	}
+
+	return conf, nil
+}